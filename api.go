@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// graphqlUrl returns the GraphQL endpoint next to the REST API,
+// https://api.github.com/graphql or https://<host>/api/graphql
+func graphqlUrl(apiUrl string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(apiUrl, "/"), "/v3") + "/graphql"
+}
+
+// runAPI sends a request with the stored token, only graphql is supported
+func runAPI(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "graphql" {
+		return errors.New("usage: api graphql [flags] < query.graphql")
+	}
+	flags := flag.NewFlagSet("api graphql", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	variables := flags.String("variables", "", "variables of the query as a JSON object")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	query, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{"query": string(query)}
+	if *variables != "" {
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(*variables), &v); err != nil {
+			return fmt.Errorf("invalid -variables: %w", err)
+		}
+		payload["variables"] = v
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	t, err := loadValidToken(ctx, st)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", graphqlUrl(st.apiUrl), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+t.AccessToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(body)
+	fmt.Println()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GraphQL request failed: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// appTokenRequest calls an endpoint of the OAuth app for a token, authenticated with
+// the client ID and secret rather than the token itself
+//
+// https://docs.github.com/en/rest/apps/oauth-applications
+func appTokenRequest(ctx context.Context, method, apiUrl, path, clientId, clientSecret, accessToken string) (*http.Response, []byte, error) {
+	if clientSecret == "" {
+		return nil, nil, errors.New("the client secret is required, use -client-secret")
+	}
+	b, err := json.Marshal(map[string]string{"access_token": accessToken})
+	if err != nil {
+		return nil, nil, err
+	}
+	u := apiUrl + "/applications/" + url.PathEscape(clientId) + path
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.SetBasicAuth(clientId, clientSecret)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// revokeToken deletes the token, or the whole grant of the app for the user
+// including all its tokens if grant is set
+//
+// https://docs.github.com/en/rest/apps/oauth-applications#delete-an-app-token
+// https://docs.github.com/en/rest/apps/oauth-applications#delete-an-app-authorization
+func revokeToken(ctx context.Context, apiUrl, clientId, clientSecret, accessToken string, grant bool) error {
+	path := "/token"
+	if grant {
+		path = "/grant"
+	}
+	resp, _, err := appTokenRequest(ctx, "DELETE", apiUrl, path, clientId, clientSecret, accessToken)
+	if err != nil {
+		return err
+	}
+	// 404 means the token is already invalid
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to revoke the token: %s", resp.Status)
+	}
+	return nil
+}
+
+// exit code of check when the token is revoked or otherwise invalid
+const exitTokenInvalid = 3
+
+type appToken struct {
+	Scopes    []string `json:"scopes"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+	ExpiresAt string   `json:"expires_at"`
+	User      user     `json:"user"`
+}
+
+// runCheck reports whether the stored token is still valid with the check token API,
+// exiting with exitTokenInvalid if it is not, e.g. for cron jobs deciding to log in again
+//
+// https://docs.github.com/en/rest/apps/oauth-applications#check-a-token
+func runCheck(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	_, t, err := loadStoredToken(ctx, st)
+	if err != nil {
+		return err
+	}
+	resp, body, err := appTokenRequest(ctx, "POST", st.apiUrl, "/token", *st.clientId, *st.clientSecret, t.AccessToken)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity {
+		return &exitError{code: exitTokenInvalid, msg: "the stored token is revoked or invalid"}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to check the token: %s", resp.Status)
+	}
+
+	at := &appToken{}
+	if err := json.Unmarshal(body, at); err != nil {
+		return err
+	}
+	fmt.Printf("valid: yes\n")
+	fmt.Printf("user: %s\n", at.User.Login)
+	fmt.Printf("scopes: %s\n", strings.Join(at.Scopes, ", "))
+	fmt.Printf("created at: %s\n", at.CreatedAt)
+	// GitHub does not expose the last use, updated_at is the last change of the authorization
+	fmt.Printf("updated at: %s\n", at.UpdatedAt)
+	if at.ExpiresAt != "" {
+		fmt.Printf("expires at: %s\n", at.ExpiresAt)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// commands are completed as the first argument
+var commands = []string{
+	"login", "logout", "revoke", "status", "token", "refresh", "whoami", "scopes", "check",
+	"exec", "inspect", "credential", "api", "completion", "help",
+}
+
+const bashCompletion = `_%[3]s() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    case "$prev" in
+        -host) COMPREPLY=($(compgen -W "$(%[1]s __hosts 2>/dev/null)" -- "$cur")); return ;;
+        -provider) COMPREPLY=($(compgen -W "github gitlab gitea google microsoft" -- "$cur")); return ;;
+        -store) COMPREPLY=($(compgen -W "keyring file gh" -- "$cur")); return ;;
+    esac
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "%[2]s" -- "$cur"))
+    fi
+}
+complete -F _%[3]s %[1]s
+`
+
+const zshCompletion = `#compdef %[1]s
+_%[3]s() {
+    case "${words[CURRENT-1]}" in
+        -host) compadd -- ${(f)"$(%[1]s __hosts 2>/dev/null)"}; return ;;
+        -provider) compadd github gitlab gitea google microsoft; return ;;
+        -store) compadd keyring file gh; return ;;
+    esac
+    if (( CURRENT == 2 )); then
+        compadd %[2]s
+    fi
+}
+compdef _%[3]s %[1]s
+`
+
+const fishCompletion = `complete -c %[1]s -f
+complete -c %[1]s -n __fish_use_subcommand -a '%[2]s'
+complete -c %[1]s -o host -x -a '(%[1]s __hosts 2>/dev/null)'
+complete -c %[1]s -o provider -x -a 'github gitlab gitea google microsoft'
+complete -c %[1]s -o store -x -a 'keyring file gh'
+`
+
+const powershellCompletion = `Register-ArgumentCompleter -Native -CommandName '%[1]s' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    $prev = if ($wordToComplete) { $words[-2] } else { $words[-1] }
+    $candidates = switch ($prev) {
+        '-host' { & '%[1]s' __hosts 2>$null }
+        '-provider' { 'github', 'gitlab', 'gitea', 'google', 'microsoft' }
+        '-store' { 'keyring', 'file', 'gh' }
+        default { if ($words.Count -le 2) { '%[2]s' -split ' ' } }
+    }
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
+
+// runCompletion prints the completion script of the shell,
+// host names are completed from the config file by the hidden __hosts command
+//
+//	source <(gh-device completion bash)
+func runCompletion(prog string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: completion bash|zsh|fish|powershell")
+	}
+	name := filepath.Base(prog)
+	fn := strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	cmds := strings.Join(commands, " ")
+
+	switch args[0] {
+	case "bash":
+		fmt.Printf(bashCompletion, name, cmds, fn)
+	case "zsh":
+		fmt.Printf(zshCompletion, name, cmds, fn)
+	case "fish":
+		fmt.Printf(fishCompletion, name, cmds)
+	case "powershell":
+		fmt.Printf(powershellCompletion, name, cmds)
+	default:
+		return fmt.Errorf("unsupported shell: %s", args[0])
+	}
+	return nil
+}
+
+// runHosts prints the hosts of the config file for completion
+func runHosts() error {
+	cfg, err := loadConfig(defaultConfigPath(), false)
+	if err != nil {
+		return err
+	}
+	hosts := make([]string, 0, len(cfg.Hosts))
+	for h := range cfg.Hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		fmt.Fprintln(os.Stdout, h)
+	}
+	return nil
+}
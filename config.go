@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config is loaded from ~/.config/gh-device/config.toml, e.g.
+//
+//	[hosts."github.com"]
+//	client_id = "Iv1.0123456789abcdef"
+//	scope = "repo read:org"
+type config struct {
+	Hosts map[string]hostConfig `toml:"hosts"`
+}
+
+type hostConfig struct {
+	ClientId       string `toml:"client_id"`
+	ClientSecret   string `toml:"client_secret"`
+	Scope          string `toml:"scope"`
+	Tenant         string `toml:"tenant"`
+	DeviceCodeUrl  string `toml:"device_code_url"`
+	AccessTokenUrl string `toml:"access_token_url"`
+	ApiUrl         string `toml:"api_url"`
+	Issuer         string `toml:"issuer"`
+	Store          string `toml:"store"`
+	Proxy          string `toml:"proxy"`
+	Socks5         string `toml:"socks5"`
+	CaFile         string `toml:"ca_file"`
+	MinTLS         string `toml:"min_tls"`
+	ClientCert     string `toml:"client_cert"`
+	ClientKey      string `toml:"client_key"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gh-device", "config.toml")
+}
+
+// loadConfig returns an empty config if the file does not exist and is not required
+func loadConfig(path string, required bool) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+	_, err := toml.DecodeFile(path, cfg)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
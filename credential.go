@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readCredentialAttributes reads key=value lines until a blank line or EOF
+//
+// https://git-scm.com/docs/git-credential#IOFMT
+func readCredentialAttributes(r io.Reader) (map[string]string, error) {
+	attrs := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 {
+			attrs[kv[0]] = kv[1]
+		}
+	}
+	return attrs, scanner.Err()
+}
+
+// runCredential implements a git credential helper, e.g.
+//
+//	git config --global credential.helper '!gh-device credential'
+//
+// https://git-scm.com/docs/gitcredentials#_custom_helpers
+func runCredential(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("credential", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	operation := flags.Arg(0)
+
+	attrs, err := readCredentialAttributes(os.Stdin)
+	if err != nil {
+		return err
+	}
+	// other protocols are not ours to answer
+	if attrs["protocol"] != "https" {
+		return nil
+	}
+	// hosts other than -host (or GITHUB_OAUTH_HOST), or else the default host of the provider
+	// and the config file sections, are left to the next helper
+	if *st.host != "" {
+		if attrs["host"] != *st.host {
+			return nil
+		}
+	} else {
+		configSet := false
+		flags.Visit(func(f *flag.Flag) { configSet = configSet || f.Name == "config" })
+		cfg, err := loadConfig(*st.configPath, configSet)
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Hosts[attrs["host"]]; !ok && attrs["host"] != providerHosts[*st.providerName] {
+			return nil
+		}
+		*st.host = attrs["host"]
+	}
+	// the token needs to be cached between git invocations
+	if *st.store == "" {
+		*st.store = "keyring"
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+	store, err := st.tokenStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch operation {
+	case "get":
+		t, err := ensureToken(ctx, st, store)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("username=x-access-token\npassword=%s\n", t.AccessToken)
+		return nil
+	case "store":
+		// the token is already stored when it is obtained
+		return nil
+	case "erase":
+		return store.erase(*st.host, *st.clientId)
+	}
+	return fmt.Errorf("unknown credential operation: %s", operation)
+}
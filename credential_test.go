@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadCredentialAttributes(t *testing.T) {
+	attrs, err := readCredentialAttributes(strings.NewReader("protocol=https\nhost=github.com\npath=a=b\n\nignored=1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 3 || attrs["protocol"] != "https" || attrs["host"] != "github.com" || attrs["path"] != "a=b" {
+		t.Errorf("attrs = %v", attrs)
+	}
+}
+
+// credential runs the credential helper with input on stdin
+func credential(t *testing.T, input string, args ...string) (stdout string, err error) {
+	t.Helper()
+	f, err := ioutil.TempFile(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = saved }()
+
+	stdout, _, err = capture(t, func() error {
+		return runCredential(context.Background(), args)
+	})
+	return stdout, err
+}
+
+func TestCredential(t *testing.T) {
+	setupEnv(t)
+	t.Setenv("GITHUB_OAUTH_STORE_PASSPHRASE", "passphrase")
+	config := writeConfig(t, `[hosts."ghe.example.com"]
+client_id = "cid"
+store = "file"
+`)
+	s := &fileStore{path: defaultTokenFilePath()}
+	if err := s.save("ghe.example.com", "cid", &storedToken{AccessToken: testToken}); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, err := credential(t, "protocol=https\nhost=ghe.example.com\n", "-config", config, "get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "username=x-access-token\npassword=" + testToken + "\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+}
+
+func TestCredentialOtherHosts(t *testing.T) {
+	setupEnv(t)
+	config := writeConfig(t, `[hosts."ghe.example.com"]
+client_id = "cid"
+`)
+	tests := []struct {
+		input string
+		args  []string
+	}{
+		{"protocol=https\nhost=gitlab.com\n", []string{"-config", config, "get"}},
+		{"protocol=http\nhost=ghe.example.com\n", []string{"-config", config, "get"}},
+		{"protocol=https\nhost=ghe.example.com\n", []string{"-config", config, "-host", "github.com", "get"}},
+	}
+	for _, tt := range tests {
+		stdout, err := credential(t, tt.input, tt.args...)
+		if err != nil || stdout != "" {
+			t.Errorf("%q %v: stdout = %q, err = %v, want nothing for the next helper", tt.input, tt.args, stdout, err)
+		}
+	}
+}
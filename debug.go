@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"os"
+	"regexp"
+	"time"
+)
+
+// debugTransport prints redacted dumps and connection timings of each request to stderr
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+var (
+	secretHeaders = regexp.MustCompile(`(?im)^(Authorization|Proxy-Authorization|Cookie|Set-Cookie):.*$`)
+	secretParams  = regexp.MustCompile(`(access_token|refresh_token|device_code|client_secret|id_token)(=|"\s*:\s*")[^&"\s]+`)
+)
+
+func redact(dump []byte) string {
+	s := secretHeaders.ReplaceAllString(string(dump), "$1: REDACTED")
+	return secretParams.ReplaceAllString(s, "${1}${2}REDACTED")
+}
+
+func debugf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "[debug] "+format+"\n", args...)
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	since := func() time.Duration { return time.Since(start).Round(time.Millisecond) }
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) { debugf("%s dns lookup %s", since(), info.Host) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			debugf("%s dns done %v err=%v", since(), info.Addrs, info.Err)
+		},
+		ConnectStart: func(network, addr string) { debugf("%s connect %s %s", since(), network, addr) },
+		ConnectDone: func(network, addr string, err error) {
+			debugf("%s connected %s %s err=%v", since(), network, addr, err)
+		},
+		TLSHandshakeStart: func() { debugf("%s tls handshake", since()) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			debugf("%s tls done version=%x cipher=%x server=%s err=%v", since(), state.Version, state.CipherSuite, state.ServerName, err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			debugf("%s got conn reused=%v addr=%s", since(), info.Reused, info.Conn.RemoteAddr())
+		},
+		GotFirstResponseByte: func() { debugf("%s first response byte", since()) },
+	}
+	// dumped before tracing, DumpRequestOut makes a round trip of its own
+	if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+		debugf("request:\n%s", redact(dump))
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		debugf("%s %s %s failed: %v", since(), req.Method, req.URL, err)
+		return nil, err
+	}
+	if dump, err := httputil.DumpResponse(resp, true); err == nil {
+		debugf("response after %s:\n%s", since(), redact(dump))
+	}
+	return resp, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	dump := "POST /login/oauth/access_token HTTP/1.1\r\n" +
+		"Authorization: Bearer gho_secret\r\n" +
+		"Cookie: session=secret\r\n" +
+		"\r\n" +
+		"client_id=cid&device_code=dc_secret&grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Adevice_code\r\n" +
+		`{"access_token":"gho_secret","refresh_token": "ghr_secret","scope":"repo"}`
+	got := redact([]byte(dump))
+	if strings.Contains(got, "secret") {
+		t.Errorf("redact = %q, contains a secret", got)
+	}
+	for _, want := range []string{"Authorization: REDACTED", "device_code=REDACTED", `"access_token":"REDACTED"`, "client_id=cid", `"scope":"repo"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("redact = %q, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package deviceflow
+
+import "net/http"
+
+// https://docs.github.com/en/rest/overview/media-types
+const githubAccept = "application/vnd.github+json"
+
+// NewHTTPClient returns a client sending the token and the GitHub JSON Accept header
+// with every request, ready to call the GitHub API.
+// It keeps the transport and the timeout of base, e.g. the HTTPClient of the flow
+// with its proxy and TLS settings; nil means http.DefaultClient.
+func NewHTTPClient(token string, base *http.Client) *http.Client {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client := *base
+	client.Transport = &authTransport{token: token, base: transport}
+	return &client
+}
+
+type authTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", githubAccept)
+	}
+	return t.base.RoundTrip(req)
+}
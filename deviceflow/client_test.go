@@ -0,0 +1,59 @@
+package deviceflow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type recordingTransport struct {
+	requests int
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer srv.Close()
+
+	base := &recordingTransport{}
+	client := NewHTTPClient("token", &http.Client{Transport: base, Timeout: time.Minute})
+	if client.Timeout != time.Minute {
+		t.Errorf("Timeout = %s, want the timeout of the base client", client.Timeout)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Get("Authorization") != "Bearer token" || got.Get("Accept") != githubAccept {
+		t.Errorf("unexpected headers: %v", got)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("the request of the caller is modified")
+	}
+	if base.requests != 1 {
+		t.Errorf("%d requests through the base transport, want 1", base.requests)
+	}
+
+	// an Accept header of the caller is kept
+	req, _ = http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Accept", "application/vnd.github.raw")
+	resp, err = NewHTTPClient("token", nil).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Get("Accept") != "application/vnd.github.raw" {
+		t.Errorf("Accept = %q", got.Get("Accept"))
+	}
+}
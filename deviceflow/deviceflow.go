@@ -0,0 +1,445 @@
+// Package deviceflow implements the OAuth 2.0 device authorization grant,
+// with presets for GitHub's OAuth device flow.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628
+// https://docs.github.com/en/developers/apps/building-oauth-apps/authorizing-oauth-apps#device-flow
+package deviceflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// fixed value
+	grantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// https://docs.github.com/en/developers/apps/building-oauth-apps/authorizing-oauth-apps#response-1
+	defaultAccept = "application/json"
+
+	defaultRequestTimeout = 30 * time.Second
+
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+	slowDownIncrease = 5 * time.Second
+	maxPollInterval  = time.Minute
+)
+
+// Flow holds the settings of a device flow against a provider.
+type Flow struct {
+	Provider Provider
+
+	// https://docs.github.com/en/developers/apps/building-oauth-apps/scopes-for-oauth-apps
+	// empty value means "read-only access to public information" on GitHub
+	Scope string
+
+	// Accept headers of the device code request and the access token request
+	DeviceCodeAccept  string
+	AccessTokenAccept string
+
+	// OnPending is called each time the user has not yet authorized the device
+	OnPending func()
+
+	// Banner is a message, e.g. product branding, for the caller to show once
+	// before the user code, it may span multiple lines; the flow does not print it
+	Banner string
+
+	// HTTPClient sends all requests of the flow
+	HTTPClient *http.Client
+
+	// RequestTimeout limits each request, polling as a whole is limited by the context
+	RequestTimeout time.Duration
+
+	// MaxAttempts is the number of tries of each request on transient failures
+	MaxAttempts int
+
+	// Logger receives the events of the flow, nil discards them
+	Logger *slog.Logger
+
+	// TracerProvider records the spans of the flow, nil means the global provider
+	TracerProvider trace.TracerProvider
+}
+
+// Option customizes a Flow created by New.
+type Option func(*Flow)
+
+// WithHTTPClient makes the flow send its requests with client,
+// e.g. to add instrumentation or a custom transport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(f *Flow) {
+		f.HTTPClient = client
+	}
+}
+
+// WithRequestTimeout limits each request to d, 0 means no limit.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(f *Flow) {
+		f.RequestTimeout = d
+	}
+}
+
+// WithLogger makes the flow log its events such as device_code_issued,
+// poll_attempt, slow_down and token_granted to logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(f *Flow) {
+		f.Logger = logger
+	}
+}
+
+// WithBanner sets the Banner shown before the user code.
+func WithBanner(banner string) Option {
+	return func(f *Flow) {
+		f.Banner = banner
+	}
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func (f *Flow) logger() *slog.Logger {
+	if f.Logger == nil {
+		return discardLogger
+	}
+	return f.Logger
+}
+
+// New returns a Flow for the provider with the default settings.
+func New(provider Provider, scope string, opts ...Option) *Flow {
+	f := &Flow{
+		Provider:          provider,
+		Scope:             scope,
+		DeviceCodeAccept:  defaultAccept,
+		AccessTokenAccept: defaultAccept,
+		OnPending:         func() {},
+		HTTPClient:        http.DefaultClient,
+		RequestTimeout:    defaultRequestTimeout,
+		MaxAttempts:       defaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+type DeviceCodeResponse struct {
+	DeviceCode      string `json:"device_code"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+
+	// VerificationURIComplete includes the user code, it is optional in RFC 8628
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-3.3.1
+	VerificationURIComplete string `json:"verification_uri_complete"`
+
+	// Google returns verification_url instead of verification_uri,
+	// RequestDeviceCode copies it to VerificationURI
+	VerificationURL string `json:"verification_url"`
+
+	// Microsoft returns the instructions for the user to display
+	Message string `json:"message"`
+}
+
+type AccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope"`
+
+	// returned for GitHub App user tokens that expire, in seconds
+	ExpiresIn             int    `json:"expires_in"`
+	RefreshToken          string `json:"refresh_token"`
+	RefreshTokenExpiresIn int    `json:"refresh_token_expires_in"`
+
+	// IDToken is returned by OpenID Connect providers when the openid scope is requested
+	IDToken string `json:"id_token"`
+}
+
+// AuthorizationHeader returns the value of the Authorization header for the token.
+//
+// https://docs.github.com/en/developers/apps/building-oauth-apps/authorizing-oauth-apps#3-use-the-access-token-to-access-the-api
+func (r *AccessTokenResponse) AuthorizationHeader() string {
+	if strings.EqualFold(r.TokenType, "bearer") {
+		return "Bearer " + r.AccessToken
+	}
+	return "token " + r.AccessToken
+}
+
+type AccessTokenErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorUri         string `json:"error_uri"`
+
+	// GitHub returns the new minimum polling interval with slow_down, in seconds
+	Interval int `json:"interval"`
+
+	// RetryAfter is taken from the Retry-After header, if any
+	RetryAfter time.Duration `json:"-"`
+}
+
+// postOnce returns the body and the delay requested by a Retry-After header
+func (f *Flow) postOnce(ctx context.Context, url, accept string, params url.Values) ([]byte, time.Duration, error) {
+	parent := ctx
+	if f.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, f.RequestTimeout, ErrRequestTimeout)
+		defer cancel()
+	}
+	timedOut := func(err error) error {
+		if parent.Err() == nil && isTimeout(err) {
+			return fmt.Errorf("%w: POST %s", ErrRequestTimeout, url)
+		}
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", accept)
+	// https://datatracker.ietf.org/doc/html/rfc6749#appendix-B
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, timedOut(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, timedOut(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// RFC 8628 error responses such as authorization_pending are sent with 400,
+		// they are handled by the callers
+		errRes := &AccessTokenErrorResponse{}
+		if json.Unmarshal(body, errRes) != nil || errRes.Error == "" {
+			return nil, 0, newHTTPError("POST", url, resp, body)
+		}
+	}
+	return body, parseRetryAfter(resp.Header), nil
+}
+
+// RequestDeviceCode requests the device and user verification codes from GitHub.
+func (f *Flow) RequestDeviceCode(ctx context.Context) (_ *DeviceCodeResponse, err error) {
+	ctx, span := f.tracer().Start(ctx, "deviceflow.RequestDeviceCode", trace.WithAttributes(
+		attribute.String("deviceflow.provider", f.Provider.Name),
+		attribute.String("deviceflow.scope", f.Scope),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if max := f.Provider.MaxParamsLength; max > 0 && len(f.Scope)+len(f.Provider.ClientId) > max {
+		return nil, fmt.Errorf("scope and client ID are %d bytes together, longer than the limit of %d", len(f.Scope)+len(f.Provider.ClientId), max)
+	}
+
+	values := f.Provider.params()
+	values.Add("scope", f.Scope)
+
+	body, _, err := f.post(ctx, f.Provider.DeviceCodeUrl, f.DeviceCodeAccept, values)
+	if err != nil {
+		return nil, err
+	}
+
+	errRes := &AccessTokenErrorResponse{}
+	err = json.Unmarshal(body, errRes)
+	if err == nil && errRes.Error == "invalid_scope" {
+		if unknown := f.Provider.UnknownScopes(ParseScopes(f.Scope)); len(unknown) > 0 {
+			return nil, fmt.Errorf("requested scope is rejected, unknown scopes: %s", strings.Join(unknown, ", "))
+		}
+		return nil, fmt.Errorf("requested scope is rejected: %s %s", errRes.ErrorDescription, errRes.ErrorUri)
+	}
+	if err == nil && errRes.Error != "" {
+		return nil, newError(errRes)
+	}
+
+	res := &DeviceCodeResponse{}
+	err = json.Unmarshal(body, res)
+	if err != nil {
+		return nil, err
+	}
+	if res.VerificationURI == "" {
+		res.VerificationURI = res.VerificationURL
+	}
+	// the user code is shown by the caller, logs may be captured where it should not leak
+	f.logger().Debug("device_code_issued",
+		"provider", f.Provider.Name,
+		"verification_uri", res.VerificationURI,
+		"expires_in", res.ExpiresIn,
+		"interval", res.Interval,
+	)
+	return res, nil
+}
+
+func (f *Flow) postAccessToken(ctx context.Context, deviceCode string) (*AccessTokenResponse, *AccessTokenErrorResponse, error) {
+	values := f.Provider.params()
+	values.Add("device_code", deviceCode)
+	values.Add("grant_type", grantType)
+	return f.postToken(ctx, values)
+}
+
+func (f *Flow) postToken(ctx context.Context, values url.Values) (*AccessTokenResponse, *AccessTokenErrorResponse, error) {
+	if f.Provider.ClientSecret != "" {
+		values.Add("client_secret", f.Provider.ClientSecret)
+	}
+
+	body, retryAfter, err := f.post(ctx, f.Provider.AccessTokenUrl, f.AccessTokenAccept, values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// an error field wins over an access token in the same response,
+	// since a token alongside an error is not a response GitHub sends
+	errRes := &AccessTokenErrorResponse{}
+	err = json.Unmarshal(body, errRes)
+	if err == nil && errRes.Error != "" {
+		errRes.RetryAfter = retryAfter
+		return nil, errRes, nil
+	}
+
+	res := &AccessTokenResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		return nil, nil, err
+	}
+	// e.g. {} from a misbehaving gateway, there is nothing to poll for or return
+	if res.AccessToken == "" {
+		return nil, nil, fmt.Errorf("no access token or error in the response of %s", f.Provider.AccessTokenUrl)
+	}
+	return res, nil, nil
+}
+
+// Refresh exchanges the refresh token of a GitHub App user token
+// (or any provider issuing refresh tokens) for a new access token.
+//
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/refreshing-user-access-tokens
+func (f *Flow) Refresh(ctx context.Context, refreshToken string) (_ *AccessTokenResponse, err error) {
+	ctx, span := f.tracer().Start(ctx, "deviceflow.Refresh", trace.WithAttributes(
+		attribute.String("deviceflow.provider", f.Provider.Name),
+	))
+	defer func() { endSpan(span, err) }()
+
+	values := f.Provider.params()
+	values.Add("refresh_token", refreshToken)
+	values.Add("grant_type", "refresh_token")
+
+	acResp, acErrResp, err := f.postToken(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+	if acErrResp != nil {
+		return nil, newError(acErrResp)
+	}
+	return acResp, nil
+}
+
+// PollAccessToken polls GitHub until the user authorizes the device,
+// the code expires at expiresAt, or ctx is done.
+func (f *Flow) PollAccessToken(ctx context.Context, deviceCode string, interval time.Duration, expiresAt time.Time) (_ *AccessTokenResponse, err error) {
+	ctx, span := f.tracer().Start(ctx, "deviceflow.PollAccessToken", trace.WithAttributes(
+		attribute.String("deviceflow.provider", f.Provider.Name),
+	))
+	attempt := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("deviceflow.attempts", attempt))
+		endSpan(span, err)
+	}()
+
+	wait := interval
+	for attempt = 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = interval
+		if time.Now().After(expiresAt) {
+			return nil, ErrExpiredToken
+		}
+
+		f.logger().Debug("poll_attempt", "attempt", attempt, "interval", interval.String())
+		attemptCtx, attemptSpan := f.tracer().Start(ctx, "deviceflow.PollAttempt", trace.WithAttributes(
+			attribute.Int("deviceflow.attempt", attempt),
+		))
+		acResp, acErrResp, err := f.postAccessToken(attemptCtx, deviceCode)
+		if acErrResp != nil {
+			attemptSpan.SetAttributes(attribute.String("oauth.error", acErrResp.Error))
+		}
+		endSpan(attemptSpan, err)
+		// keep polling when rate limited, after the delay the server asks for
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+			if httpErr.RetryAfter > wait {
+				wait = httpErr.RetryAfter
+			}
+			f.logger().Debug("rate_limited", "attempt", attempt, "wait", wait.String())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if acErrResp != nil {
+			if acErrResp.RetryAfter > wait {
+				wait = acErrResp.RetryAfter
+			}
+			// https://docs.github.com/ja/developers/apps/building-oauth-apps/authorizing-oauth-apps#error-codes-for-the-device-flow
+			if acErrResp.Error == "authorization_pending" {
+				f.OnPending()
+				continue
+			}
+			if acErrResp.Error == "slow_down" {
+				interval = slowDownInterval(interval, acErrResp.Interval)
+				if interval > wait {
+					wait = interval
+				}
+				f.logger().Debug("slow_down", "attempt", attempt, "interval", interval.String())
+				continue
+			}
+			if acErrResp.Error != "" {
+				f.logger().Debug("poll_failed", "attempt", attempt, "error", acErrResp.Error)
+				return nil, newError(acErrResp)
+			}
+		}
+
+		if acResp != nil {
+			span.AddEvent("token_granted", trace.WithAttributes(
+				attribute.String("oauth.token_type", acResp.TokenType),
+				attribute.String("oauth.scope", acResp.Scope),
+			))
+			f.logger().Debug("token_granted",
+				"attempts", attempt,
+				"token_type", acResp.TokenType,
+				"scope", acResp.Scope,
+				"expires_in", acResp.ExpiresIn,
+			)
+		}
+		return acResp, nil
+	}
+}
+
+// slowDownInterval returns the polling interval after a slow_down response
+// carrying serverInterval seconds, 0 if it has none
+func slowDownInterval(interval time.Duration, serverInterval int) time.Duration {
+	if serverInterval > 0 {
+		// the server's interval is honored as is, polling faster only draws more slow_down
+		return time.Duration(serverInterval) * time.Second
+	}
+	if interval >= maxPollInterval {
+		return interval
+	}
+	// only the local increments are capped
+	interval += slowDownIncrease
+	if interval > maxPollInterval {
+		interval = maxPollInterval
+	}
+	return interval
+}
@@ -0,0 +1,415 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tokenServer answers the device code request with dcBody
+// and the access token requests with tokenBodies in turn, repeating the last one
+type tokenServer struct {
+	*httptest.Server
+
+	dcBody      string
+	tokenBodies []string
+
+	mu       sync.Mutex
+	requests []*http.Request
+	forms    []map[string]string
+	polls    int
+}
+
+func newTokenServer(t *testing.T, dcBody string, tokenBodies ...string) *tokenServer {
+	t.Helper()
+	s := &tokenServer{dcBody: dcBody, tokenBodies: tokenBodies}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := make(map[string]string)
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.forms = append(s.forms, form)
+		i := s.polls
+		if r.URL.Path != "/device" {
+			s.polls++
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/device" {
+			fmt.Fprint(w, s.dcBody)
+			return
+		}
+		if i >= len(s.tokenBodies) {
+			i = len(s.tokenBodies) - 1
+		}
+		fmt.Fprint(w, s.tokenBodies[i])
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tokenServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func newTestFlow(url string) *Flow {
+	provider := Provider{
+		Name:           "test",
+		DeviceCodeUrl:  url + "/device",
+		AccessTokenUrl: url + "/token",
+		ClientId:       "client-id",
+	}
+	return New(provider, "repo read:org", WithMaxAttempts(1))
+}
+
+const testDeviceCode = `{"device_code":"dc","user_code":"ABCD-1234","verification_uri":"https://example.com/device","expires_in":900,"interval":5}`
+
+func TestRequestDeviceCode(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode)
+	f := newTestFlow(s.URL)
+
+	dcResp, err := f.RequestDeviceCode(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dcResp.DeviceCode != "dc" || dcResp.UserCode != "ABCD-1234" || dcResp.ExpiresIn != 900 || dcResp.Interval != 5 {
+		t.Errorf("unexpected response: %+v", dcResp)
+	}
+	if dcResp.VerificationURI != "https://example.com/device" {
+		t.Errorf("VerificationURI = %q", dcResp.VerificationURI)
+	}
+	form := s.forms[0]
+	if form["client_id"] != "client-id" || form["scope"] != "repo read:org" {
+		t.Errorf("unexpected form: %v", form)
+	}
+}
+
+func TestRequestDeviceCodeVerificationURL(t *testing.T) {
+	s := newTokenServer(t, `{"device_code":"dc","user_code":"ABCD","verification_url":"https://www.google.com/device","expires_in":1800,"interval":5}`)
+	f := newTestFlow(s.URL)
+
+	dcResp, err := f.RequestDeviceCode(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dcResp.VerificationURI != "https://www.google.com/device" {
+		t.Errorf("VerificationURI = %q, want the verification_url", dcResp.VerificationURI)
+	}
+}
+
+func TestRequestDeviceCodeParamsTooLong(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode)
+	f := newTestFlow(s.URL)
+	f.Provider.MaxParamsLength = 20
+	f.Scope = "repo read:org gist"
+
+	_, err := f.RequestDeviceCode(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "27 bytes together, longer than the limit of 20") {
+		t.Errorf("err = %v, want the length and the limit", err)
+	}
+	if len(s.forms) != 0 {
+		t.Errorf("requests = %d, want none", len(s.forms))
+	}
+
+	f.Provider.MaxParamsLength = len(f.Scope) + len(f.Provider.ClientId)
+	if _, err := f.RequestDeviceCode(context.Background()); err != nil {
+		t.Errorf("err = %v, want the limit to be inclusive", err)
+	}
+}
+
+func TestRequestDeviceCodeInvalidScope(t *testing.T) {
+	s := newTokenServer(t, `{"error":"invalid_scope"}`)
+	f := newTestFlow(s.URL)
+	f.Provider.Scopes = ValidScopes
+	f.Scope = "repo reop"
+
+	_, err := f.RequestDeviceCode(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unknown scopes: reop") {
+		t.Errorf("err = %v, want the unknown scope", err)
+	}
+}
+
+func TestRequestDeviceCodeErrorResponse(t *testing.T) {
+	s := newTokenServer(t, `{"error":"device_flow_disabled","error_description":"Device Flow must be explicitly enabled for this App"}`)
+	f := newTestFlow(s.URL)
+
+	_, err := f.RequestDeviceCode(context.Background())
+	if !errors.Is(err, ErrDeviceFlowDisabled) {
+		t.Errorf("err = %v, want ErrDeviceFlowDisabled", err)
+	}
+	var flowErr *Error
+	if !errors.As(err, &flowErr) || flowErr.Code != "device_flow_disabled" {
+		t.Errorf("err = %#v, want *Error", err)
+	}
+}
+
+func TestAcceptHeaders(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{"access_token":"token","token_type":"bearer"}`)
+	f := newTestFlow(s.URL)
+	f.DeviceCodeAccept = "application/vnd.device+json"
+	f.AccessTokenAccept = "application/vnd.token+json"
+
+	if _, err := f.RequestDeviceCode(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.PollAccessToken(context.Background(), "dc", time.Millisecond, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.requests[0].Header.Get("Accept"); got != "application/vnd.device+json" {
+		t.Errorf("Accept of the device code request = %q", got)
+	}
+	if got := s.requests[1].Header.Get("Accept"); got != "application/vnd.token+json" {
+		t.Errorf("Accept of the access token request = %q", got)
+	}
+}
+
+func TestPollAccessTokenPending(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode,
+		`{"error":"authorization_pending"}`,
+		`{"error":"authorization_pending"}`,
+		`{"access_token":"token","token_type":"bearer","scope":"repo,read:org"}`,
+	)
+	f := newTestFlow(s.URL)
+	pending := 0
+	f.OnPending = func() { pending++ }
+
+	acResp, err := f.PollAccessToken(context.Background(), "dc", time.Millisecond, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acResp.AccessToken != "token" || acResp.Scope != "repo,read:org" {
+		t.Errorf("unexpected response: %+v", acResp)
+	}
+	if pending != 2 {
+		t.Errorf("OnPending called %d times, want 2", pending)
+	}
+	form := s.forms[len(s.forms)-1]
+	if form["device_code"] != "dc" || form["grant_type"] != grantType || form["client_id"] != "client-id" {
+		t.Errorf("unexpected form: %v", form)
+	}
+}
+
+func TestPollAccessTokenSlowDown(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode,
+		`{"error":"slow_down"}`,
+		`{"access_token":"token","token_type":"bearer"}`,
+	)
+	f := newTestFlow(s.URL)
+
+	// the next poll is 5 seconds later, after the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_, err := f.PollAccessToken(ctx, "dc", 10*time.Millisecond, time.Now().Add(time.Minute))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline of the context", err)
+	}
+	if n := s.count(); n != 1 {
+		t.Errorf("%d polls, want 1", n)
+	}
+}
+
+func TestSlowDownInterval(t *testing.T) {
+	tests := []struct {
+		interval       time.Duration
+		serverInterval int
+		want           time.Duration
+	}{
+		{6 * time.Second, 0, 11 * time.Second},
+		{6 * time.Second, 10, 10 * time.Second},
+		{58 * time.Second, 0, time.Minute},
+		{time.Minute, 0, time.Minute},
+		// the server's interval is never capped or lowered
+		{6 * time.Second, 90, 90 * time.Second},
+		{90 * time.Second, 0, 90 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := slowDownInterval(tt.interval, tt.serverInterval); got != tt.want {
+			t.Errorf("slowDownInterval(%s, %d) = %s, want %s", tt.interval, tt.serverInterval, got, tt.want)
+		}
+	}
+}
+
+func TestPollAccessTokenRetryAfter(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"token","token_type":"bearer"}`)
+	}))
+	defer srv.Close()
+	f := newTestFlow(srv.URL)
+
+	start := time.Now()
+	acResp, err := f.PollAccessToken(context.Background(), "dc", 10*time.Millisecond, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acResp.AccessToken != "token" {
+		t.Errorf("AccessToken = %q", acResp.AccessToken)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("polled again after %s, before the Retry-After of 1s", elapsed)
+	}
+}
+
+func TestPollAccessTokenExpired(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{"error":"authorization_pending"}`)
+	f := newTestFlow(s.URL)
+
+	_, err := f.PollAccessToken(context.Background(), "dc", 10*time.Millisecond, time.Now().Add(50*time.Millisecond))
+	if !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("err = %v, want ErrExpiredToken", err)
+	}
+}
+
+func TestPollAccessTokenExpiredResponse(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{"error":"expired_token"}`)
+	f := newTestFlow(s.URL)
+
+	_, err := f.PollAccessToken(context.Background(), "dc", time.Millisecond, time.Now().Add(time.Minute))
+	if !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("err = %v, want ErrExpiredToken", err)
+	}
+}
+
+func TestPollAccessTokenCanceled(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{"error":"authorization_pending"}`)
+	f := newTestFlow(s.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	f.OnPending = cancel
+
+	_, err := f.PollAccessToken(ctx, "dc", time.Millisecond, time.Now().Add(time.Minute))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestPollAccessTokenErrorWinsOverToken(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{"access_token":"token","error":"access_denied"}`)
+	f := newTestFlow(s.URL)
+
+	_, err := f.PollAccessToken(context.Background(), "dc", time.Millisecond, time.Now().Add(time.Minute))
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("err = %v, want ErrAccessDenied", err)
+	}
+}
+
+func TestPollAccessTokenEmptyResponse(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{}`)
+	f := newTestFlow(s.URL)
+
+	acResp, err := f.PollAccessToken(context.Background(), "dc", time.Millisecond, time.Now().Add(time.Minute))
+	if err == nil || !strings.Contains(err.Error(), "no access token or error") {
+		t.Errorf("err = %v, want an error for a response without a token", err)
+	}
+	if acResp != nil {
+		t.Errorf("acResp = %+v, want nil", acResp)
+	}
+	if _, err := f.Refresh(context.Background(), "r1"); err == nil {
+		t.Error("Refresh err = nil, want an error for a response without a token")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{"access_token":"new","token_type":"bearer","expires_in":28800,"refresh_token":"r2"}`)
+	f := newTestFlow(s.URL)
+	f.Provider.ClientSecret = "secret"
+
+	acResp, err := f.Refresh(context.Background(), "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acResp.AccessToken != "new" || acResp.RefreshToken != "r2" || acResp.ExpiresIn != 28800 {
+		t.Errorf("unexpected response: %+v", acResp)
+	}
+	form := s.forms[0]
+	if form["grant_type"] != "refresh_token" || form["refresh_token"] != "r1" || form["client_secret"] != "secret" {
+		t.Errorf("unexpected form: %v", form)
+	}
+}
+
+func TestRefreshError(t *testing.T) {
+	s := newTokenServer(t, testDeviceCode, `{"error":"bad_refresh_token","error_description":"The refresh token passed is incorrect or expired."}`)
+	f := newTestFlow(s.URL)
+
+	_, err := f.Refresh(context.Background(), "r1")
+	var flowErr *Error
+	if !errors.As(err, &flowErr) || flowErr.Code != "bad_refresh_token" {
+		t.Errorf("err = %v, want *Error with bad_refresh_token", err)
+	}
+}
+
+func TestPostOnceStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantHTTP bool
+	}{
+		{"ok", http.StatusOK, `{"access_token":"token"}`, false},
+		{"oauth error with 400", http.StatusBadRequest, `{"error":"authorization_pending"}`, false},
+		{"not json", http.StatusBadGateway, `<html>Bad Gateway</html>`, true},
+		{"json without error", http.StatusNotFound, `{"message":"Not Found"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			f := newTestFlow(srv.URL)
+
+			body, _, err := f.postOnce(context.Background(), srv.URL, defaultAccept, nil)
+			var httpErr *HTTPError
+			if tt.wantHTTP {
+				if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+					t.Fatalf("err = %v, want *HTTPError with %d", err, tt.status)
+				}
+				if httpErr.Method != "POST" || httpErr.Url != srv.URL || httpErr.Body != tt.body {
+					t.Errorf("unexpected HTTPError: %+v", httpErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestAuthorizationHeader(t *testing.T) {
+	tests := []struct {
+		tokenType string
+		want      string
+	}{
+		{"bearer", "Bearer t"},
+		{"Bearer", "Bearer t"},
+		{"", "token t"},
+	}
+	for _, tt := range tests {
+		r := &AccessTokenResponse{AccessToken: "t", TokenType: tt.tokenType}
+		if got := r.AuthorizationHeader(); got != tt.want {
+			t.Errorf("AuthorizationHeader() with %q = %q, want %q", tt.tokenType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package deviceflow
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors returned by the flow, wrapped in *Error when they come from an error response.
+//
+// https://docs.github.com/en/developers/apps/building-oauth-apps/authorizing-oauth-apps#error-codes-for-the-device-flow
+var (
+	ErrAccessDenied               = errors.New("the user canceled the authorization")
+	ErrExpiredToken               = errors.New("the device code has expired")
+	ErrUnsupportedGrantType       = errors.New("the grant type is not supported")
+	ErrIncorrectClientCredentials = errors.New("the client ID is incorrect")
+	ErrDeviceFlowDisabled         = errors.New("the device flow is not enabled for the app")
+)
+
+// ErrRequestTimeout is returned when a single request exceeds Flow.RequestTimeout
+// or the timeout of the HTTP client, unlike the deadline of the caller's context
+// which is returned as is.
+var ErrRequestTimeout = errors.New("the request timed out")
+
+var errorCodes = map[string]error{
+	"access_denied":                ErrAccessDenied,
+	"expired_token":                ErrExpiredToken,
+	"unsupported_grant_type":       ErrUnsupportedGrantType,
+	"incorrect_client_credentials": ErrIncorrectClientCredentials,
+	"device_flow_disabled":         ErrDeviceFlowDisabled,
+}
+
+// Error is an error response of the provider.
+// errors.Is reports whether it matches one of the Err values above by its code.
+type Error struct {
+	Code        string
+	Description string
+	Uri         string
+}
+
+func newError(r *AccessTokenErrorResponse) *Error {
+	return &Error{Code: r.Error, Description: r.ErrorDescription, Uri: r.ErrorUri}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s %s", e.Code, e.Description, e.Uri)
+}
+
+func (e *Error) Unwrap() error {
+	return errorCodes[e.Code]
+}
+
+// bodies longer than this are truncated in HTTPError
+const maxErrorBody = 512
+
+// HTTPError is a non-2xx response that is not an error response of the OAuth protocol.
+type HTTPError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Status     string
+	Body       string
+
+	// RetryAfter is taken from the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func newHTTPError(method, url string, resp *http.Response, body []byte) *HTTPError {
+	b := string(body)
+	if len(b) > maxErrorBody {
+		b = b[:maxErrorBody] + "..."
+	}
+	return &HTTPError{
+		Method:     method,
+		Url:        url,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       b,
+		RetryAfter: parseRetryAfter(resp.Header),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Url, e.Status, strings.TrimSpace(e.Body))
+}
+
+// Temporary reports whether the request may succeed if retried later,
+// i.e. a 5xx or 429 rather than a permanent 4xx failure.
+func (e *HTTPError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,67 @@
+package deviceflow
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"access_denied", ErrAccessDenied},
+		{"expired_token", ErrExpiredToken},
+		{"unsupported_grant_type", ErrUnsupportedGrantType},
+		{"incorrect_client_credentials", ErrIncorrectClientCredentials},
+		{"device_flow_disabled", ErrDeviceFlowDisabled},
+	}
+	for _, tt := range tests {
+		err := error(newError(&AccessTokenErrorResponse{Error: tt.code}))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s does not match %v", tt.code, tt.want)
+		}
+	}
+
+	err := error(newError(&AccessTokenErrorResponse{Error: "incorrect_device_code", ErrorDescription: "The device_code provided is not valid."}))
+	for _, tt := range tests {
+		if errors.Is(err, tt.want) {
+			t.Errorf("incorrect_device_code matches %v", tt.want)
+		}
+	}
+	if !strings.Contains(err.Error(), "incorrect_device_code The device_code provided is not valid.") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestHTTPErrorTemporary(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusNotFound, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		e := &HTTPError{StatusCode: tt.status}
+		if got := e.Temporary(); got != tt.want {
+			t.Errorf("Temporary() with %d = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestNewHTTPErrorTruncatesBody(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Header: http.Header{"Retry-After": {"3"}}}
+	e := newHTTPError("POST", "https://example.com", resp, []byte(strings.Repeat("x", 1000)))
+	if len(e.Body) != maxErrorBody+len("...") {
+		t.Errorf("len(Body) = %d", len(e.Body))
+	}
+	if e.RetryAfter.Seconds() != 3 {
+		t.Errorf("RetryAfter = %s", e.RetryAfter)
+	}
+}
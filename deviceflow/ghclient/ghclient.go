@@ -0,0 +1,35 @@
+// Package ghclient builds GitHub API clients from the token issued by the device flow.
+// It is separate from deviceflow so the flow does not depend on the client libraries.
+package ghclient
+
+import (
+	"net/http"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/shurcooL/githubv4"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// New returns a go-github client for host, e.g. "github.com" or the hostname of a GitHub Enterprise Server.
+// Requests go through base as in deviceflow.NewHTTPClient, nil means http.DefaultClient.
+//
+// https://pkg.go.dev/github.com/google/go-github/v58/github#Client.WithEnterpriseURLs
+func New(host, token string, base *http.Client) (*github.Client, error) {
+	client := github.NewClient(deviceflow.NewHTTPClient(token, base))
+	if host == "" || host == "github.com" {
+		return client, nil
+	}
+	return client.WithEnterpriseURLs("https://"+host+"/api/v3/", "https://"+host+"/api/uploads/")
+}
+
+// NewGraphQL returns a githubv4 client for host, sending requests through base like New.
+//
+// https://docs.github.com/en/graphql/guides/forming-calls-with-graphql#the-graphql-endpoint
+func NewGraphQL(host, token string, base *http.Client) *githubv4.Client {
+	client := deviceflow.NewHTTPClient(token, base)
+	if host == "" || host == "github.com" {
+		return githubv4.NewClient(client)
+	}
+	return githubv4.NewEnterpriseClient("https://"+host+"/api/graphql", client)
+}
@@ -0,0 +1,80 @@
+package ghclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	client, err := New("github.com", "token", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.BaseURL.String(); got != "https://api.github.com/" {
+		t.Errorf("BaseURL = %q", got)
+	}
+
+	client, err = New("ghes.example.com", "token", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.BaseURL.String(); got != "https://ghes.example.com/api/v3/" {
+		t.Errorf("BaseURL = %q", got)
+	}
+	if got := client.UploadURL.String(); got != "https://ghes.example.com/api/uploads/" {
+		t.Errorf("UploadURL = %q", got)
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewGraphQL(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"viewer":{"login":"octocat"}}}`))
+	}))
+	defer srv.Close()
+
+	base := &countingTransport{}
+	// the host is a test server over plain HTTP, so the endpoint is rewritten by the transport
+	client := NewGraphQL("github.com", "token", &http.Client{Transport: rewriteTransport{url: srv.URL, base: base}})
+	var q struct {
+		Viewer struct {
+			Login string
+		}
+	}
+	if err := client.Query(context.Background(), &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if q.Viewer.Login != "octocat" || auth != "Bearer token" {
+		t.Errorf("login = %q, Authorization = %q", q.Viewer.Login, auth)
+	}
+	if base.requests != 1 {
+		t.Errorf("%d requests through the base transport, want 1", base.requests)
+	}
+}
+
+type rewriteTransport struct {
+	url  string
+	base http.RoundTripper
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, err := http.NewRequestWithContext(req.Context(), req.Method, t.url+req.URL.Path, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Header = req.Header
+	return t.base.RoundTrip(r)
+}
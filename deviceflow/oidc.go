@@ -0,0 +1,346 @@
+package deviceflow
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IDTokenClaims holds the validated claims of an OpenID Connect ID token.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type IDTokenClaims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	Expiry   int64  `json:"exp"`
+	IssuedAt int64  `json:"iat"`
+	Nonce    string `json:"nonce"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+
+	// Audience is either a string or an array of strings in the token
+	Audience []string `json:"-"`
+
+	// Raw holds all claims including the ones above
+	Raw map[string]interface{} `json:"-"`
+}
+
+// JWT is a decoded, not yet verified, JSON Web Token.
+type JWT struct {
+	Header    map[string]interface{}
+	Claims    map[string]interface{}
+	Signature []byte
+
+	signed string
+	raw    []byte
+}
+
+// ParseJWT decodes a compact serialized JWT without verifying it.
+func ParseJWT(token string) (*JWT, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT: expected 3 parts")
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT signature: %w", err)
+	}
+
+	t := &JWT{Signature: sig, signed: parts[0] + "." + parts[1], raw: claims}
+	if err := json.Unmarshal(header, &t.Header); err != nil {
+		return nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	if err := json.Unmarshal(claims, &t.Claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	return t, nil
+}
+
+func (t *JWT) headerString(key string) string {
+	s, _ := t.Header[key].(string)
+	return s
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError("GET", url, resp, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// DiscoverJWKSUrl returns the jwks_uri of the issuer's OpenID Provider configuration.
+//
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfig
+func DiscoverJWKSUrl(ctx context.Context, issuer string) (string, error) {
+	return discoverJWKSUrl(ctx, http.DefaultClient, issuer)
+}
+
+// DiscoverJWKSUrlWithClient is DiscoverJWKSUrl sending the request with client,
+// e.g. one with the proxy and TLS settings of the flow; nil means http.DefaultClient.
+func DiscoverJWKSUrlWithClient(ctx context.Context, client *http.Client, issuer string) (string, error) {
+	return discoverJWKSUrl(ctx, client, issuer)
+}
+
+func discoverJWKSUrl(ctx context.Context, client *http.Client, issuer string) (string, error) {
+	cfg, err := discover(ctx, client, issuer)
+	if err != nil {
+		return "", err
+	}
+	return cfg.JWKSUri, nil
+}
+
+type providerConfig struct {
+	Issuer  string `json:"issuer"`
+	JWKSUri string `json:"jwks_uri"`
+}
+
+func discover(ctx context.Context, client *http.Client, issuer string) (*providerConfig, error) {
+	cfg := &providerConfig{}
+	if err := getJSON(ctx, client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", cfg); err != nil {
+		return nil, err
+	}
+	if cfg.JWKSUri == "" {
+		return nil, errors.New("issuer does not publish jwks_uri")
+	}
+	return cfg, nil
+}
+
+// VerifySignature verifies the signature of t with the keys fetched from jwksUrl.
+func (t *JWT) VerifySignature(ctx context.Context, jwksUrl string) error {
+	return t.verifySignature(ctx, http.DefaultClient, jwksUrl)
+}
+
+// VerifySignatureWithClient is VerifySignature fetching the keys with client; nil means http.DefaultClient.
+func (t *JWT) VerifySignatureWithClient(ctx context.Context, client *http.Client, jwksUrl string) error {
+	return t.verifySignature(ctx, client, jwksUrl)
+}
+
+func (t *JWT) verifySignature(ctx context.Context, client *http.Client, jwksUrl string) error {
+	keys := &jwks{}
+	if err := getJSON(ctx, client, jwksUrl, keys); err != nil {
+		return err
+	}
+
+	kid := t.headerString("kid")
+	for _, k := range keys.Keys {
+		if kid != "" && k.Kid != kid {
+			continue
+		}
+		if err := t.verifyWith(k); err == nil {
+			return nil
+		}
+	}
+	return errors.New("JWT signature is not valid for any key in the JWKS")
+}
+
+func (t *JWT) verifyWith(k jwk) error {
+	alg := t.headerString("alg")
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported JWT algorithm: %s", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported JWT algorithm: %s", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(t.signed))
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS") && k.Kty == "RSA":
+		pub, err := rsaPublicKey(k)
+		if err != nil {
+			return err
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, t.Signature)
+	case strings.HasPrefix(alg, "ES") && k.Kty == "EC":
+		pub, err := ecdsaPublicKey(k)
+		if err != nil {
+			return err
+		}
+		if len(t.Signature) == 0 || len(t.Signature)%2 != 0 {
+			return errors.New("invalid ECDSA signature length")
+		}
+		size := len(t.Signature) / 2
+		r := new(big.Int).SetBytes(t.Signature[:size])
+		s := new(big.Int).SetBytes(t.Signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported JWT algorithm %s for key type %s", alg, k.Kty)
+}
+
+func rsaPublicKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func ecdsaPublicKey(k jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// VerifyIDToken verifies the signature of the ID token with the issuer's JWKS,
+// and its issuer, audience and expiry, and returns the claims.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func VerifyIDToken(ctx context.Context, idToken, issuer, audience string) (*IDTokenClaims, error) {
+	return verifyIDToken(ctx, http.DefaultClient, idToken, issuer, nil, audience)
+}
+
+// VerifyIDTokenWithClient is VerifyIDToken fetching the configuration and the keys with client;
+// nil means http.DefaultClient.
+func VerifyIDTokenWithClient(ctx context.Context, client *http.Client, idToken, issuer, audience string) (*IDTokenClaims, error) {
+	return verifyIDToken(ctx, client, idToken, issuer, nil, audience)
+}
+
+// VerifyIDToken verifies the ID token against the issuer and issuer aliases of the provider
+// with the HTTP client of the flow.
+func (f *Flow) VerifyIDToken(ctx context.Context, idToken string) (*IDTokenClaims, error) {
+	return verifyIDToken(ctx, f.HTTPClient, idToken, f.Provider.Issuer, f.Provider.IssuerAliases, f.Provider.ClientId)
+}
+
+func verifyIDToken(ctx context.Context, client *http.Client, idToken, issuer string, aliases []string, audience string) (*IDTokenClaims, error) {
+	t, err := ParseJWT(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := discover(ctx, client, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verifySignature(ctx, client, cfg.JWKSUri); err != nil {
+		return nil, err
+	}
+
+	claims := &IDTokenClaims{Raw: t.Claims}
+	if err := json.Unmarshal(t.raw, claims); err != nil {
+		return nil, err
+	}
+	switch aud := t.Claims["aud"].(type) {
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
+	}
+
+	issuers := append([]string{issuer, strings.TrimSuffix(issuer, "/")}, aliases...)
+	if cfg.Issuer != "" {
+		issuers = append(issuers, cfg.Issuer)
+	}
+	if !contains(issuers, claims.Issuer) {
+		return nil, fmt.Errorf("ID token issuer mismatch: %s", claims.Issuer)
+	}
+	if !contains(claims.Audience, audience) {
+		return nil, fmt.Errorf("ID token audience does not contain %s", audience)
+	}
+	if time.Now().After(time.Unix(claims.Expiry, 0)) {
+		return nil, errors.New("ID token is expired")
+	}
+	return claims, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
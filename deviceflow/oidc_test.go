@@ -0,0 +1,134 @@
+package deviceflow
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oidcServer publishes a discovery document with discoveredIssuer and the JWKS of key
+func newOIDCServer(t *testing.T, key *rsa.PrivateKey, discoveredIssuer string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := discoveredIssuer
+		if issuer == "" {
+			issuer = srv.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": srv.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newOIDCServer(t, key, "")
+	// a tenant given as a domain, the discovery document has the issuer with the tenant ID
+	tenant := newOIDCServer(t, key, "https://login.microsoftonline.com/9188040d/v2.0")
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": srv.URL,
+			"sub": "user",
+			"aud": "client-id",
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"iat": time.Now().Unix(),
+		}
+	}
+	tests := []struct {
+		name    string
+		issuer  string
+		aliases []string
+		key     *rsa.PrivateKey
+		claims  func(map[string]interface{})
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "audience array", claims: func(c map[string]interface{}) { c["aud"] = []string{"other", "client-id"} }},
+		{name: "issuer alias", aliases: []string{"accounts.google.com"}, claims: func(c map[string]interface{}) { c["iss"] = "accounts.google.com" }},
+		{name: "discovered issuer", issuer: tenant.URL, claims: func(c map[string]interface{}) { c["iss"] = "https://login.microsoftonline.com/9188040d/v2.0" }},
+		{name: "wrong issuer", claims: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, wantErr: "issuer mismatch"},
+		{name: "wrong audience", claims: func(c map[string]interface{}) { c["aud"] = "other" }, wantErr: "audience"},
+		{name: "expired", claims: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: "expired"},
+		{name: "wrong key", key: other, wantErr: "signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			signer := key
+			if tt.key != nil {
+				signer = tt.key
+			}
+			issuer := srv.URL
+			if tt.issuer != "" {
+				issuer = tt.issuer
+			}
+			got, err := verifyIDToken(context.Background(), http.DefaultClient, signJWT(t, signer, claims), issuer, tt.aliases, "client-id")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != "user" || got.Issuer != claims["iss"] {
+				t.Errorf("unexpected claims: %+v", got)
+			}
+		})
+	}
+}
+
+func TestParseJWTMalformed(t *testing.T) {
+	for _, token := range []string{"", "a.b", "a.b.c.d", "!!.e30.", "e30.!!."} {
+		if _, err := ParseJWT(token); err == nil {
+			t.Errorf("ParseJWT(%q) succeeded", token)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package deviceflow
+
+import "net/url"
+
+// Provider describes a device authorization server.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628
+type Provider struct {
+	Name string
+
+	DeviceCodeUrl  string
+	AccessTokenUrl string
+
+	ClientId string
+
+	// ClientSecret is sent with the access token request if set,
+	// some providers require it even for the device flow
+	ClientSecret string
+
+	// ExtraParams are sent with both the device code and the access token requests
+	ExtraParams url.Values
+
+	// Scopes lists the known scope names, nil means scopes are not validated
+	Scopes []string
+
+	// MaxParamsLength limits the length of the scope and the client ID together,
+	// checked before the device code request, 0 means no limit
+	MaxParamsLength int
+
+	// Issuer is the OpenID Connect issuer used to verify ID tokens,
+	// empty means ID tokens are not verified
+	Issuer string
+
+	// IssuerAliases are other iss values the provider is documented to use,
+	// the issuer of the discovery document is accepted as well
+	IssuerAliases []string
+}
+
+// GitHub returns the provider for github.com or a GitHub Enterprise Server host.
+func GitHub(host, clientId string) Provider {
+	return Provider{
+		Name:            "github",
+		DeviceCodeUrl:   "https://" + host + "/login/device/code",
+		AccessTokenUrl:  "https://" + host + "/login/oauth/access_token",
+		ClientId:        clientId,
+		Scopes:          ValidScopes,
+		MaxParamsLength: gitHubMaxParamsLength,
+	}
+}
+
+// https://docs.gitlab.com/ee/integration/oauth_provider.html#view-all-authorized-applications
+var GitLabScopes = []string{
+	"api", "read_api", "read_user", "create_runner", "manage_runner", "k8s_proxy",
+	"read_repository", "write_repository", "read_registry", "write_registry",
+	"sudo", "admin_mode", "read_service_ping",
+	"openid", "profile", "email",
+	"ai_features",
+}
+
+// GitLab returns the provider for gitlab.com or a self-managed GitLab host.
+//
+// https://docs.gitlab.com/ee/api/oauth2.html#device-authorization-grant-flow
+func GitLab(host, clientId string) Provider {
+	return Provider{
+		Name:           "gitlab",
+		DeviceCodeUrl:  "https://" + host + "/oauth/authorize_device",
+		AccessTokenUrl: "https://" + host + "/oauth/token",
+		ClientId:       clientId,
+		Scopes:         GitLabScopes,
+		Issuer:         "https://" + host,
+	}
+}
+
+// Gitea returns the provider for a Gitea or Forgejo instance (Gitea 1.20 or later).
+//
+// https://docs.gitea.com/development/oauth2-provider
+func Gitea(host, clientId string) Provider {
+	return Provider{
+		Name:           "gitea",
+		DeviceCodeUrl:  "https://" + host + "/login/oauth/device/code",
+		AccessTokenUrl: "https://" + host + "/login/oauth/access_token",
+		ClientId:       clientId,
+	}
+}
+
+// Google returns the provider for Google OAuth 2.0 for TV and limited-input device applications,
+// which requires the client secret as well.
+//
+// https://developers.google.com/identity/protocols/oauth2/limited-input-device
+func Google(clientId, clientSecret string) Provider {
+	return Provider{
+		Name:           "google",
+		DeviceCodeUrl:  "https://oauth2.googleapis.com/device/code",
+		AccessTokenUrl: "https://oauth2.googleapis.com/token",
+		ClientId:       clientId,
+		ClientSecret:   clientSecret,
+		Issuer:         "https://accounts.google.com",
+		// https://developers.google.com/identity/openid-connect/openid-connect#validatinganidtoken
+		IssuerAliases: []string{"accounts.google.com"},
+	}
+}
+
+// Microsoft returns the provider for the Microsoft identity platform,
+// tenant is "common", "organizations", "consumers" or a tenant ID or domain.
+// ID tokens are verified only for a specific tenant, since the issuer
+// of the multi-tenant endpoints depends on the signed-in user.
+// For a domain the iss of the token has the tenant ID instead,
+// which is taken from the discovery document.
+//
+// https://learn.microsoft.com/en-us/entra/identity-platform/v2-oauth2-device-code
+func Microsoft(tenant, clientId string) Provider {
+	base := "https://login.microsoftonline.com/" + url.PathEscape(tenant)
+	p := Provider{
+		Name:           "microsoft",
+		DeviceCodeUrl:  base + "/oauth2/v2.0/devicecode",
+		AccessTokenUrl: base + "/oauth2/v2.0/token",
+		ClientId:       clientId,
+	}
+	if tenant != "common" && tenant != "organizations" && tenant != "consumers" {
+		p.Issuer = base + "/v2.0"
+	}
+	return p
+}
+
+// UnknownScopes returns the scopes not listed in p.Scopes.
+func (p Provider) UnknownScopes(scopes []string) []string {
+	unknown := make([]string, 0)
+	if p.Scopes == nil {
+		return unknown
+	}
+	for _, s := range scopes {
+		found := false
+		for _, v := range p.Scopes {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, s)
+		}
+	}
+	return unknown
+}
+
+func (p Provider) params() url.Values {
+	values := url.Values{}
+	values.Add("client_id", p.ClientId)
+	for k, vs := range p.ExtraParams {
+		for _, v := range vs {
+			values.Add(k, v)
+		}
+	}
+	return values
+}
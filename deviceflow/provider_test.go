@@ -0,0 +1,35 @@
+package deviceflow
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMicrosoftIssuer(t *testing.T) {
+	tests := []struct {
+		tenant string
+		issuer string
+	}{
+		{"common", ""},
+		{"organizations", ""},
+		{"consumers", ""},
+		{"9188040d-6c67-4c5b-b112-36a304b66dad", "https://login.microsoftonline.com/9188040d-6c67-4c5b-b112-36a304b66dad/v2.0"},
+	}
+	for _, tt := range tests {
+		p := Microsoft(tt.tenant, "client-id")
+		if p.Issuer != tt.issuer {
+			t.Errorf("Issuer of %s = %q, want %q", tt.tenant, p.Issuer, tt.issuer)
+		}
+		if want := "https://login.microsoftonline.com/" + tt.tenant + "/oauth2/v2.0/devicecode"; p.DeviceCodeUrl != want {
+			t.Errorf("DeviceCodeUrl = %q, want %q", p.DeviceCodeUrl, want)
+		}
+	}
+}
+
+func TestProviderParams(t *testing.T) {
+	p := Provider{ClientId: "client-id", ExtraParams: url.Values{"audience": {"api"}}}
+	values := p.params()
+	if values.Get("client_id") != "client-id" || values.Get("audience") != "api" {
+		t.Errorf("params() = %v", values)
+	}
+}
@@ -0,0 +1,104 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 3
+
+	// backoff starts at retryBaseDelay and doubles up to retryMaxDelay
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// WithMaxAttempts makes each request be tried up to n times on network errors and 5xx responses,
+// 1 disables retries.
+func WithMaxAttempts(n int) Option {
+	return func(f *Flow) {
+		f.MaxAttempts = n
+	}
+}
+
+// post retries postOnce with exponential backoff and jitter
+func (f *Flow) post(ctx context.Context, url, accept string, params url.Values) ([]byte, time.Duration, error) {
+	for attempt := 1; ; attempt++ {
+		body, retryAfter, err := f.postOnce(ctx, url, accept, params)
+		if err == nil || attempt >= f.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return body, retryAfter, err
+		}
+		delay := backoff(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+			delay = httpErr.RetryAfter
+		}
+		f.logger().Warn("request_retry", "url", url, "attempt", attempt, "delay", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// parseRetryAfter returns 0 if the header is missing or malformed
+//
+// https://www.rfc-editor.org/rfc/rfc9110#field.retry-after
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func retryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Temporary()
+	}
+	if errors.Is(err, ErrRequestTimeout) {
+		return true
+	}
+	// DNS failures, connection resets
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isTimeout reports whether err comes from a deadline of the request or of the HTTP client
+func isTimeout(err error) bool {
+	// net/http may report the cause of the request context instead of its error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrRequestTimeout) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff returns a random delay between half and all of the exponential delay of the attempt
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << uint(attempt-1)
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,142 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostRetries(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token"}`))
+	}))
+	defer srv.Close()
+	f := newTestFlow(srv.URL)
+	f.MaxAttempts = 3
+
+	body, _, err := f.post(context.Background(), srv.URL, defaultAccept, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"access_token":"token"}` {
+		t.Errorf("body = %q", body)
+	}
+	if requests != 3 {
+		t.Errorf("%d requests, want 3", requests)
+	}
+}
+
+func TestPostGivesUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   int
+	}{
+		{"5xx until max attempts", http.StatusBadGateway, 2},
+		{"4xx is not retried", http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			f := newTestFlow(srv.URL)
+			f.MaxAttempts = 2
+
+			_, _, err := f.post(context.Background(), srv.URL, defaultAccept, nil)
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+				t.Errorf("err = %v, want *HTTPError with %d", err, tt.status)
+			}
+			if requests != tt.want {
+				t.Errorf("%d requests, want %d", requests, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+	f := newTestFlow(srv.URL)
+	f.RequestTimeout = 50 * time.Millisecond
+
+	_, err := f.RequestDeviceCode(context.Background())
+	if !errors.Is(err, ErrRequestTimeout) {
+		t.Errorf("err = %v, want ErrRequestTimeout", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v is the deadline of the caller's context", err)
+	}
+}
+
+func TestRequestTimeoutCallerDeadline(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+	f := newTestFlow(srv.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := f.RequestDeviceCode(ctx)
+	if errors.Is(err, ErrRequestTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline of the caller's context", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 120 * time.Second, 120 * time.Second},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.value != "" {
+			h.Set("Retry-After", tt.value)
+		}
+		if got := parseRetryAfter(h); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := retryBaseDelay << uint(attempt-1)
+		if d > retryMaxDelay {
+			d = retryMaxDelay
+		}
+		for i := 0; i < 20; i++ {
+			if got := backoff(attempt); got < d/2 || got > d {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempt, got, d/2, d)
+			}
+		}
+	}
+}
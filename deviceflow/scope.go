@@ -0,0 +1,90 @@
+package deviceflow
+
+import (
+	"sort"
+	"strings"
+)
+
+// https://docs.github.com/en/developers/apps/building-oauth-apps/scopes-for-oauth-apps#available-scopes
+var ValidScopes = []string{
+	"repo", "repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events",
+	"admin:repo_hook", "write:repo_hook", "read:repo_hook",
+	"admin:org", "write:org", "read:org",
+	"admin:public_key", "write:public_key", "read:public_key",
+	"admin:org_hook",
+	"gist",
+	"notifications",
+	"user", "read:user", "user:email", "user:follow",
+	"project", "read:project",
+	"delete_repo",
+	"write:packages", "read:packages", "delete:packages",
+	"admin:gpg_key", "write:gpg_key", "read:gpg_key",
+	"codespace",
+	"workflow",
+	"write:discussion", "read:discussion",
+	"admin:ssh_signing_key", "write:ssh_signing_key", "read:ssh_signing_key",
+	"admin:enterprise", "manage_runners:enterprise", "manage_billing:enterprise", "read:enterprise",
+	"audit_log", "read:audit_log",
+	"copilot", "manage_billing:copilot",
+}
+
+// gitHubMaxParamsLength is not a limit documented by GitHub: every scope in ValidScopes
+// joined with spaces is under 700 bytes and client IDs are 20 characters,
+// so a longer value is a mistake such as a repeated or pasted scope list.
+const gitHubMaxParamsLength = 1024
+
+// ParseScopes returns the sorted, deduplicated scopes of a scope string.
+// GitHub returns granted scopes comma separated (e.g. "repo,gist"),
+// but space separated values are accepted as well.
+func ParseScopes(scope string) []string {
+	set := make(map[string]struct{})
+	for _, s := range strings.FieldsFunc(scope, func(r rune) bool { return r == ',' || r == ' ' }) {
+		set[s] = struct{}{}
+	}
+	scopes := make([]string, 0, len(set))
+	for s := range set {
+		scopes = append(scopes, s)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// impliedScopes are the scopes included in a broader scope, e.g. repo includes public_repo
+var impliedScopes = map[string][]string{
+	"repo":                  {"repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events"},
+	"admin:repo_hook":       {"write:repo_hook", "read:repo_hook"},
+	"write:repo_hook":       {"read:repo_hook"},
+	"admin:org":             {"write:org", "read:org"},
+	"write:org":             {"read:org"},
+	"admin:public_key":      {"write:public_key", "read:public_key"},
+	"write:public_key":      {"read:public_key"},
+	"user":                  {"read:user", "user:email", "user:follow"},
+	"project":               {"read:project"},
+	"write:packages":        {"read:packages"},
+	"admin:gpg_key":         {"write:gpg_key", "read:gpg_key"},
+	"write:gpg_key":         {"read:gpg_key"},
+	"write:discussion":      {"read:discussion"},
+	"admin:ssh_signing_key": {"write:ssh_signing_key", "read:ssh_signing_key"},
+	"write:ssh_signing_key": {"read:ssh_signing_key"},
+	"admin:enterprise":      {"manage_runners:enterprise", "manage_billing:enterprise", "read:enterprise"},
+	"audit_log":             {"read:audit_log"},
+}
+
+// MissingScopes returns the required scopes that are neither granted
+// nor included in a granted scope.
+func MissingScopes(granted, required []string) []string {
+	have := make(map[string]bool)
+	for _, g := range granted {
+		have[g] = true
+		for _, s := range impliedScopes[g] {
+			have[s] = true
+		}
+	}
+	missing := make([]string, 0)
+	for _, r := range required {
+		if !have[r] {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,52 @@
+package deviceflow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{"", []string{}},
+		{"repo,gist", []string{"gist", "repo"}},
+		{"repo, read:org", []string{"read:org", "repo"}},
+		{"repo read:org repo", []string{"read:org", "repo"}},
+	}
+	for _, tt := range tests {
+		if got := ParseScopes(tt.scope); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseScopes(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	tests := []struct {
+		granted  []string
+		required []string
+		want     []string
+	}{
+		{[]string{"repo"}, []string{"repo"}, []string{}},
+		{[]string{"repo"}, []string{"public_repo", "repo:status"}, []string{}},
+		{[]string{"admin:org"}, []string{"read:org"}, []string{}},
+		{[]string{"read:org"}, []string{"admin:org"}, []string{"admin:org"}},
+		{[]string{}, []string{"gist", "repo"}, []string{"gist", "repo"}},
+	}
+	for _, tt := range tests {
+		if got := MissingScopes(tt.granted, tt.required); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MissingScopes(%v, %v) = %v, want %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownScopes(t *testing.T) {
+	if got := GitHub("github.com", "").UnknownScopes([]string{"repo", "reop", "read:org"}); !reflect.DeepEqual(got, []string{"reop"}) {
+		t.Errorf("UnknownScopes = %v", got)
+	}
+	// scopes of providers without a list are not validated
+	if got := Gitea("gitea.com", "").UnknownScopes([]string{"anything"}); len(got) != 0 {
+		t.Errorf("UnknownScopes = %v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package deviceflow
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenSource is an oauth2.TokenSource running the device flow on the first call of Token,
+// and returning the cached token thereafter. An expired token is refreshed if possible,
+// otherwise the device flow runs again.
+type TokenSource struct {
+	flow   *Flow
+	ctx    context.Context
+	prompt func(*DeviceCodeResponse)
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+var _ oauth2.TokenSource = (*TokenSource)(nil)
+
+// TokenSource returns a TokenSource calling prompt to show the user code to the user.
+// ctx is used for the requests of the flow, oauth2.TokenSource has no context of its own.
+func (f *Flow) TokenSource(ctx context.Context, prompt func(*DeviceCodeResponse)) *TokenSource {
+	return &TokenSource{flow: f, ctx: ctx, prompt: prompt}
+}
+
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+	if s.token != nil && s.token.RefreshToken != "" {
+		if acResp, err := s.flow.Refresh(s.ctx, s.token.RefreshToken); err == nil {
+			s.token = toOAuth2Token(acResp)
+			return s.token, nil
+		}
+	}
+
+	dcResp, err := s.flow.RequestDeviceCode(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.prompt(dcResp)
+	interval := time.Duration(dcResp.Interval+1) * time.Second
+	expiresAt := time.Now().Add(time.Duration(dcResp.ExpiresIn) * time.Second)
+	acResp, err := s.flow.PollAccessToken(s.ctx, dcResp.DeviceCode, interval, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	s.token = toOAuth2Token(acResp)
+	return s.token, nil
+}
+
+func toOAuth2Token(r *AccessTokenResponse) *oauth2.Token {
+	t := &oauth2.Token{
+		AccessToken:  r.AccessToken,
+		TokenType:    r.TokenType,
+		RefreshToken: r.RefreshToken,
+	}
+	if r.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return t
+}
@@ -0,0 +1,42 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTokenSource(t *testing.T) {
+	s := newTokenServer(t, `{"device_code":"dc","user_code":"ABCD","verification_uri":"https://example.com/device","expires_in":900,"interval":0}`,
+		`{"access_token":"first","token_type":"bearer","expires_in":1,"refresh_token":"r1"}`,
+		`{"access_token":"refreshed","token_type":"bearer","expires_in":28800,"refresh_token":"r2"}`,
+	)
+	f := newTestFlow(s.URL)
+	prompts := 0
+	ts := f.TokenSource(context.Background(), func(*DeviceCodeResponse) { prompts++ })
+
+	token, err := ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "first" || prompts != 1 {
+		t.Fatalf("token = %q after %d prompts", token.AccessToken, prompts)
+	}
+
+	// expires within the expiry delta of oauth2.Token, so it is refreshed without a prompt
+	token, err = ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "refreshed" || prompts != 1 {
+		t.Fatalf("token = %q after %d prompts", token.AccessToken, prompts)
+	}
+	if form := s.forms[len(s.forms)-1]; form["refresh_token"] != "r1" {
+		t.Errorf("unexpected refresh form: %v", form)
+	}
+
+	// cached while valid
+	n := s.count()
+	if token, err = ts.Token(); err != nil || token.AccessToken != "refreshed" || s.count() != n {
+		t.Errorf("token = %v, err = %v after %d more requests", token, err, s.count()-n)
+	}
+}
@@ -0,0 +1,40 @@
+package deviceflow
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+
+// WithTracerProvider makes the flow record its spans with tp instead of the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(f *Flow) {
+		f.TracerProvider = tp
+	}
+}
+
+func (f *Flow) tracer() trace.Tracer {
+	tp := f.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// endSpan records err, with the OAuth error code if there is one, and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		var oauthErr *Error
+		if errors.As(err, &oauthErr) {
+			span.SetAttributes(attribute.String("oauth.error", oauthErr.Code))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+)
+
+// runExec runs a command with GITHUB_TOKEN and GH_TOKEN set to a valid token,
+// running the device flow first if needed. The token is never printed.
+//
+//	gh-device exec -store keyring -- gh repo list
+func runExec(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("exec", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: exec [flags] -- command [args...]")
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	store, err := st.tokenStore(ctx)
+	if err != nil {
+		return err
+	}
+	t, err := ensureToken(ctx, st, store)
+	if err != nil {
+		return err
+	}
+
+	// not bound to ctx, the command handles Ctrl-C itself
+	cmd := exec.Command(flags.Arg(0), flags.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GITHUB_TOKEN="+t.AccessToken, "GH_TOKEN="+t.AccessToken)
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// exit with the code of the command
+		return &exitError{code: exitErr.ExitCode()}
+	}
+	return err
+}
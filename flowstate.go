@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// flowState is a requested device code kept until the flow completes,
+// so a login interrupted by Ctrl-C or a crash can resume polling the same code
+type flowState struct {
+	DeviceCode *deviceflow.DeviceCodeResponse `json:"device_code"`
+	ExpiresAt  time.Time                      `json:"expires_at"`
+}
+
+// one state per endpoint, client and scope, a different scope needs a new code
+func flowStatePath(flow *deviceflow.Flow) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(flow.Provider.DeviceCodeUrl + " " + flow.Provider.ClientId + " " + flow.Scope))
+	return filepath.Join(dir, "gh-device", "flow-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadFlowState returns nil if there is no state or the code has expired
+func loadFlowState(path string) *flowState {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	s := &flowState{}
+	if err := json.Unmarshal(b, s); err != nil || s.DeviceCode == nil {
+		return nil
+	}
+	// leave a margin so the user has time to enter the code
+	if time.Now().Add(time.Minute).After(s.ExpiresAt) {
+		os.Remove(path)
+		return nil
+	}
+	return s
+}
+
+func saveFlowState(path string, s *flowState) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// the device code can be exchanged for a token, keep it private
+	return writeFileAtomic(path, b, 0600)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+func TestFlowState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gh-device", "flow.json")
+	if s := loadFlowState(path); s != nil {
+		t.Fatalf("loadFlowState without a file = %+v, want nil", s)
+	}
+
+	dc := &deviceflow.DeviceCodeResponse{DeviceCode: "dc", UserCode: testUserCode}
+	if err := saveFlowState(path, &flowState{DeviceCode: dc, ExpiresAt: time.Now().Add(10 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("stat = %v, %v, want mode 0600", fi, err)
+	}
+	s := loadFlowState(path)
+	if s == nil || s.DeviceCode.DeviceCode != "dc" || s.DeviceCode.UserCode != testUserCode {
+		t.Errorf("loadFlowState = %+v", s)
+	}
+
+	// too close to the expiry for the user to enter the code
+	if err := saveFlowState(path, &flowState{DeviceCode: dc, ExpiresAt: time.Now().Add(30 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if s := loadFlowState(path); s != nil {
+		t.Errorf("loadFlowState of an expiring code = %+v, want nil", s)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the expired state is kept: %v", err)
+	}
+}
+
+func TestFlowStatePath(t *testing.T) {
+	setupEnv(t)
+	provider := deviceflow.GitHub("github.com", "cid")
+	a := flowStatePath(deviceflow.New(provider, "repo"))
+	b := flowStatePath(deviceflow.New(provider, "repo read:org"))
+	if a == b {
+		t.Errorf("flowStatePath = %s for different scopes", a)
+	}
+	if a != flowStatePath(deviceflow.New(provider, "repo")) {
+		t.Error("flowStatePath is not stable")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// httpClient sends all requests, it is configured from the settings by resolve
+var httpClient = http.DefaultClient
+
+func (s *settings) newHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honored unless -proxy is given
+	// https://pkg.go.dev/net/http#ProxyFromEnvironment
+	transport.Proxy = http.ProxyFromEnvironment
+	if *s.proxy != "" {
+		u, err := url.Parse(*s.proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL: %s", *s.proxy)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	// net/http dials socks5 proxies itself, host names are resolved by the proxy
+	if *s.socks5 != "" {
+		if *s.proxy != "" {
+			return nil, errors.New("-proxy and -socks5 cannot be used together")
+		}
+		u, err := url.Parse("socks5://" + *s.socks5)
+		if err != nil || u.Port() == "" {
+			return nil, fmt.Errorf("invalid SOCKS5 proxy, expected [user:password@]host:port: %s", *s.socks5)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	var rt http.RoundTripper = transport
+	if *s.debug {
+		rt = &debugTransport{base: transport}
+	}
+	return &http.Client{Transport: rt, Timeout: *s.timeout}, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func (s *settings) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	if *s.minTLS != "" {
+		v, ok := tlsVersions[*s.minTLS]
+		if !ok {
+			return nil, fmt.Errorf("invalid minimum TLS version: %s", *s.minTLS)
+		}
+		cfg.MinVersion = v
+	}
+
+	// the certificates are added to the system roots, e.g. for a private CA of a GHES
+	// or a TLS-intercepting proxy
+	if *s.caFile != "" {
+		pem, err := ioutil.ReadFile(*s.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", *s.caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	// presented to gateways requiring mutual TLS
+	if *s.clientCert != "" || *s.clientKey != "" {
+		if *s.clientCert == "" || *s.clientKey == "" {
+			return nil, errors.New("-client-cert and -client-key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(*s.clientCert, *s.clientKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// runInspect decodes a JWT given as an argument or on stdin and prints it
+func runInspect(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	verify := flags.Bool("verify", false, "verify the signature with the keys of -jwks-url")
+	jwksUrl := flags.String("jwks-url", "", "JWKS URL used by -verify")
+	issuer := flags.String("issuer", "", "OpenID Connect issuer to discover the JWKS URL from when -jwks-url is not set")
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// -verify fetches the keys through the proxy and with the CA and client certificates of the settings
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	token := flags.Arg(0)
+	if token == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.New("no token given")
+		}
+		token = strings.TrimSpace(line)
+	}
+
+	t, err := deviceflow.ParseJWT(token)
+	if err != nil {
+		return err
+	}
+
+	header, err := json.MarshalIndent(t.Header, "", "  ")
+	if err != nil {
+		return err
+	}
+	claims, err := json.MarshalIndent(t.Claims, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println("header:")
+	fmt.Println(string(header))
+	fmt.Println("claims:")
+	fmt.Println(string(claims))
+
+	if exp, ok := t.Claims["exp"].(float64); ok {
+		expiresAt := time.Unix(int64(exp), 0)
+		if time.Now().After(expiresAt) {
+			fmt.Printf("expired at %s\n", expiresAt.Format(time.RFC3339))
+		} else {
+			fmt.Printf("expires at %s (in %s)\n", expiresAt.Format(time.RFC3339), time.Until(expiresAt).Round(time.Second))
+		}
+	}
+	// "scope" is a space separated string (RFC 8693), "scp" is also used by Microsoft
+	for _, key := range []string{"scope", "scp"} {
+		if v, ok := t.Claims[key].(string); ok {
+			fmt.Println("scopes:", strings.Join(deviceflow.ParseScopes(v), " "))
+		}
+	}
+
+	if !*verify {
+		return nil
+	}
+	if *jwksUrl == "" {
+		if *issuer == "" {
+			return errors.New("-verify requires -jwks-url or -issuer")
+		}
+		*jwksUrl, err = deviceflow.DiscoverJWKSUrlWithClient(ctx, httpClient, *issuer)
+		if err != nil {
+			return err
+		}
+	}
+	if err := t.VerifySignatureWithClient(ctx, httpClient, *jwksUrl); err != nil {
+		return err
+	}
+	fmt.Println("signature: valid")
+	return nil
+}
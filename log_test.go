@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	for _, format := range []string{"text", "json", "JSON"} {
+		if _, err := newLogger("debug", format); err != nil {
+			t.Errorf("newLogger(debug, %s) = %v", format, err)
+		}
+	}
+	if _, err := newLogger("verbose", "text"); err == nil {
+		t.Error("newLogger with an invalid level = nil, want an error")
+	}
+	if _, err := newLogger("info", "yaml"); err == nil {
+		t.Error("newLogger with an invalid format = nil, want an error")
+	}
+}
+
+func TestResolveLogLevel(t *testing.T) {
+	setupEnv(t)
+	if err := resolveError(t, "-log-level", "verbose"); err == nil || !strings.Contains(err.Error(), "invalid log level") {
+		t.Errorf("err = %v, want an invalid log level", err)
+	}
+}
@@ -1,176 +1,629 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
 )
 
 const (
-	// Client ID
+	// default Client ID, can be overridden with -client-id
 	oauthClientId = ""
 
-	deviceCodeUrl  = "https://github.com/login/device/code"
-	accessTokenUrl = "https://github.com/login/oauth/access_token"
-
 	// https://docs.github.com/en/developers/apps/building-oauth-apps/scopes-for-oauth-apps
 	// empty value means "read-only access to public information"
-	scope = ""
-
-	// fixed value
-	grantType = "urn:ietf:params:oauth:grant-type:device_code"
+	// default scope, can be overridden with -scope
+	defaultScope = ""
 )
 
-type deviceCodeResponse struct {
-	DeviceCode      string `json:"device_code"`
-	ExpiresIn       int    `json:"expires_in"`
-	Interval        int    `json:"interval"`
-	UserCode        string `json:"user_code"`
-	VerificationURI string `json:"verification_uri"`
+// fingerprint identifies the access token in logs without revealing it
+func fingerprint(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:8])
 }
 
-func post(url string, params url.Values) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, strings.NewReader(params.Encode()))
+// checkDeviceCodeEndpoint sends a request without a client id,
+// the endpoint is reachable if it answers with an OAuth error as GitHub does.
+// Other responses, e.g. 407 from a proxy or the page of a captive portal, mean it is not.
+func checkDeviceCodeEndpoint(ctx context.Context, deviceCodeUrl string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", deviceCodeUrl, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	// https://docs.github.com/en/developers/apps/building-oauth-apps/authorizing-oauth-apps#response-1
 	req.Header.Set("Accept", "application/json")
 
-	client := new(http.Client)
+	client := &http.Client{Transport: httpClient.Transport, Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s is unreachable: %w", deviceCodeUrl, err)
 	}
 	defer resp.Body.Close()
 
-	return ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return fmt.Errorf("%s is unreachable: %w", deviceCodeUrl, err)
+	}
+	errResp := &deviceflow.AccessTokenErrorResponse{}
+	if err := json.Unmarshal(body, errResp); err != nil || errResp.Error == "" {
+		return fmt.Errorf("%s is unreachable: %s is not an OAuth error response", deviceCodeUrl, resp.Status)
+	}
+
+	tlsVersion := "no TLS"
+	if resp.TLS != nil {
+		switch resp.TLS.Version {
+		case tls.VersionTLS10:
+			tlsVersion = "TLS 1.0"
+		case tls.VersionTLS11:
+			tlsVersion = "TLS 1.1"
+		case tls.VersionTLS12:
+			tlsVersion = "TLS 1.2"
+		case tls.VersionTLS13:
+			tlsVersion = "TLS 1.3"
+		}
+	}
+	fmt.Printf("%s is reachable (%s, %s)\n", deviceCodeUrl, resp.Status, tlsVersion)
+	return nil
 }
 
-func postDeviceCode() (*deviceCodeResponse, error) {
-	values := url.Values{}
-	values.Add("client_id", oauthClientId)
-	values.Add("scope", scope)
+func get(ctx context.Context, url, accessToken string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Authorization", "token "+accessToken)
 
-	body, err := post(deviceCodeUrl, values)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	res := &deviceCodeResponse{}
-	err = json.Unmarshal(body, res)
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return res, nil
+	return resp, body, nil
 }
 
-type accessTokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	Scope       string `json:"scope"`
-}
+// https://docs.github.com/en/rest/overview/other-authentication-methods#authenticating-for-saml-sso
+func checkOrgSSO(ctx context.Context, out io.Writer, apiUrl, accessToken, org string) error {
+	resp, _, err := get(ctx, apiUrl+"/orgs/"+url.PathEscape(org), accessToken)
+	if err != nil {
+		return err
+	}
 
-type accessTokenErrorResponse struct {
-	Error            string `json:"error"`
-	ErrorDescription string `json:"error_description"`
-	ErrorUri         string `json:"error_uri"`
+	// e.g. "X-GitHub-SSO: required; url=https://github.com/orgs/octo-org/sso?authorization_request=..."
+	sso := resp.Header.Get("X-GitHub-SSO")
+	if resp.StatusCode == http.StatusForbidden && strings.HasPrefix(sso, "required") {
+		fmt.Fprintf(out, "The access token is not authorized for SAML SSO in %s.\n", org)
+		for _, v := range strings.Split(sso, ";") {
+			v = strings.TrimSpace(v)
+			if strings.HasPrefix(v, "url=") {
+				fmt.Fprintf(out, "Open %s in your browser to authorize it.\n", strings.TrimPrefix(v, "url="))
+			}
+		}
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to check organization %s: %s", org, resp.Status)
+	}
+	return nil
 }
 
-func postAccessToken(deviceCode string) (*accessTokenResponse, *accessTokenErrorResponse, error) {
-	values := url.Values{}
-	values.Add("client_id", oauthClientId)
-	values.Add("device_code", deviceCode)
-	values.Add("grant_type", grantType)
+type user struct {
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
 
-	body, err := post(accessTokenUrl, values)
+// https://docs.github.com/en/rest/reference/users#get-the-authenticated-user
+func getUser(ctx context.Context, apiUrl, accessToken string) (*user, error) {
+	resp, body, err := get(ctx, apiUrl+"/user", accessToken)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-
-	res := &accessTokenResponse{}
-	err = json.Unmarshal(body, res)
-	if err == nil && res.AccessToken != "" {
-		return res, nil, nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the authenticated user: %s", resp.Status)
 	}
 
-	errRes := &accessTokenErrorResponse{}
-	err = json.Unmarshal(body, errRes)
-	if err == nil && errRes.Error != "" {
-		return nil, errRes, nil
+	u := &user{}
+	err = json.Unmarshal(body, u)
+	if err != nil {
+		return nil, err
 	}
+	return u, nil
+}
 
-	return nil, nil, err
+// envTokenKeys are the environment variables holding tokens for host,
+// the same as the GitHub CLI reads, so that github.com tokens are never sent to another host
+//
+// https://cli.github.com/manual/gh_help_environment
+func envTokenKeys(host string) []string {
+	if host == "github.com" {
+		return []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	}
+	return []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
 }
 
-func pollAccessToken(deviceCode string, interval time.Duration, expiresAt time.Time) (*accessTokenResponse, error) {
-	for {
-		time.Sleep(interval)
-		if time.Now().After(expiresAt) {
-			return nil, errors.New("code is already expired")
+// validToken returns the first of the environment tokens for host and the stored token
+// that the API accepts and that has all the requested scopes, or nil if there is none.
+// A stored token about to expire is refreshed first if it can be, refreshed reports that.
+func validToken(ctx context.Context, st *settings, store tokenStore, scopes []string) (_ *deviceflow.AccessTokenResponse, refreshed bool) {
+	candidates := make([]*deviceflow.AccessTokenResponse, 0)
+	for _, key := range envTokenKeys(*st.host) {
+		if v := os.Getenv(key); v != "" {
+			candidates = append(candidates, &deviceflow.AccessTokenResponse{AccessToken: v})
 		}
-
-		acResp, acErrResp, err := postAccessToken(deviceCode)
-		if err != nil {
-			return nil, err
+	}
+	var refreshedToken string
+	if store != nil {
+		if t, err := store.load(*st.host, *st.clientId); err == nil {
+			if t.needsRefresh() && t.canRefresh() {
+				if nt, err := refreshStoredToken(ctx, st, store, t); err == nil {
+					t, refreshedToken = nt, nt.AccessToken
+				} else {
+					logger.Warn("failed to refresh the stored token", "error", err)
+				}
+			}
+			if !t.needsRefresh() {
+				candidates = append(candidates, &deviceflow.AccessTokenResponse{AccessToken: t.AccessToken, TokenType: t.TokenType, Scope: t.Scope})
+			}
 		}
+	}
 
-		if acErrResp != nil {
-			// https://docs.github.com/ja/developers/apps/building-oauth-apps/authorizing-oauth-apps#error-codes-for-the-device-flow
-			if acErrResp.Error == "authorization_pending" {
-				continue
-			}
-			if acErrResp.Error == "slow_down" {
-				interval *= 2
+	for _, c := range candidates {
+		resp, _, err := get(ctx, st.apiUrl+"/user", c.AccessToken)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+		// tokens without the header have no scopes to check
+		if granted, ok := grantedScopes(resp); ok {
+			c.Scope = strings.Join(granted, ",")
+			if len(deviceflow.MissingScopes(granted, scopes)) > 0 {
 				continue
 			}
-			if acErrResp.Error != "" {
-				err := fmt.Errorf("%s %s %s", acErrResp.Error, acErrResp.ErrorDescription, acErrResp.ErrorUri)
-				return nil, err
-			}
 		}
+		return c, c.AccessToken == refreshedToken
+	}
+	return nil, false
+}
 
-		return acResp, nil
+// grantedScopes returns the scopes in the X-OAuth-Scopes header of an API response,
+// ok is false if there is no header, e.g. for GitHub App user tokens
+//
+// https://docs.github.com/en/developers/apps/building-oauth-apps/scopes-for-oauth-apps#checking-for-scopes
+func grantedScopes(resp *http.Response) (scopes []string, ok bool) {
+	v, ok := resp.Header["X-Oauth-Scopes"]
+	if !ok {
+		return nil, false
 	}
+	return deviceflow.ParseScopes(strings.Join(v, ",")), true
 }
 
-func run(args []string) error {
-	// https://docs.github.com/ja/developers/apps/building-oauth-apps/authorizing-oauth-apps#device-flow
+// envOr returns the environment variable if set, flags still take precedence over it
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
 
-	// Step 1: App requests the device and user verification codes from GitHub
-	deviceCodeRequestTime := time.Now()
-	dcResp, err := postDeviceCode()
-	if err != nil {
+const usage = `Usage: %[1]s [command] [flags]
+
+Commands:
+  login       authorize with the device flow (default)
+  logout      revoke and remove the stored token (alias: revoke)
+  status      show whether a valid token is stored and its scopes
+  token       print the stored token
+  refresh     refresh the stored token
+  inspect     decode a JWT
+  whoami      show the user and the scopes of the stored token
+  scopes      check that the stored token has the required scopes
+  check       check the stored token with the check token API of the OAuth app
+  exec        run a command with GITHUB_TOKEN and GH_TOKEN set
+  credential  git credential helper
+  api         send a GraphQL query from stdin with the stored token
+  completion  print the completion script of bash, zsh, fish or powershell
+
+Run "%[1]s <command> -h" for the flags of each command.
+`
+
+func run(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		switch args[1] {
+		case "help":
+			fmt.Fprintf(os.Stderr, usage, args[0])
+			return nil
+		case "inspect":
+			return runInspect(ctx, args[2:])
+		case "login":
+			return runLogin(ctx, args[0]+" login", args[2:])
+		case "token":
+			return runToken(ctx, args[2:])
+		case "logout", "revoke":
+			return runLogout(ctx, args[2:])
+		case "status":
+			return runStatus(ctx, args[2:])
+		case "refresh":
+			return runRefresh(ctx, args[2:])
+		case "credential":
+			return runCredential(ctx, args[2:])
+		case "check":
+			return runCheck(ctx, args[2:])
+		case "scopes":
+			return runScopes(ctx, args[2:])
+		case "whoami":
+			return runWhoami(ctx, args[2:])
+		case "exec":
+			return runExec(ctx, args[2:])
+		case "completion":
+			return runCompletion(args[0], args[2:])
+		case "__hosts":
+			return runHosts()
+		case "api":
+			return runAPI(ctx, args[2:])
+		}
+	}
+	return runLogin(ctx, args[0], args[1:])
+}
+
+var errMaxTotalRuntime = errors.New("maximum total runtime exceeded")
+
+// runLogin runs the device flow
+func runLogin(ctx context.Context, name string, args []string) (err error) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	org := flags.String("org", "", "organization to check SAML SSO authorization for after authentication")
+	maxTotalRuntime := flags.Duration("max-total-runtime", 0, "upper bound on the total runtime, 0 means no limit")
+	authorizationHeaderFile := flags.String("authorization-header-file", "", "file to write the Authorization header line to")
+	shell := flags.Bool("shell", false, "launch $SHELL with GITHUB_TOKEN set instead of printing the access token")
+	shellGhToken := flags.Bool("shell-gh-token", false, "also set GH_TOKEN in the -shell environment and the -output shell exports")
+	printLogin := flags.Bool("print-login", false, "print only the login of the authenticated user instead of the access token")
+	auditLog := flags.Bool("syslog", false, "record the token issuance (never the token itself) to the system log")
+	banner := flags.String("banner", "", "message printed before the device flow prompt")
+	notify := flags.Bool("notify", false, "also send the user code as a desktop notification")
+	showScopes := flags.Bool("show-scopes", false, "print the scopes that would be requested and exit")
+	once := flags.Bool("once", false, "only print the access token, all other side effects are disabled")
+	hyperlinks := flags.String("hyperlinks", "auto", "print the verification URI as an OSC 8 hyperlink: auto, always or never")
+	deviceCodeAccept := flags.String("device-code-accept", "application/json", "Accept header of the device code request")
+	accessTokenAccept := flags.String("access-token-accept", "application/json", "Accept header of the access token request")
+	gitCredentials := flags.Bool("git-credentials", false, "write the access token to ~/.git-credentials for the store credential helper")
+	grace := flags.Duration("grace", 0, "delay after authorization before the first API call")
+	tokenFd := flags.Int("token-fd", 0, "file descriptor to write the access token to, prompts go to stderr")
+	checkNetwork := flags.Bool("check-network", false, "only check that the device code endpoint is reachable and exit")
+	compact := flags.Bool("compact", false, "print a single line prompt suitable for embedding in other CLIs")
+	waitMessage := flags.String("wait-message", "Still waiting for you to authorize in the browser...", "message printed periodically while waiting for authorization")
+	waitMessageInterval := flags.Duration("wait-message-interval", 30*time.Second, "interval between wait messages, 0 disables them")
+	qrCode := flags.Bool("qr", false, "also print the verification URI as a QR code when the prompt goes to a terminal")
+	noBrowser := flags.Bool("no-browser", false, "do not open the verification URI in the browser")
+	copyCode := flags.Bool("copy-code", false, "copy the user code to the clipboard")
+	tui := flags.Bool("tui", false, "show a full-screen login screen with a countdown when the prompt goes to a terminal")
+	output := flags.String("output", "text", "output format: text, json for one JSON document per event on stdout, or shell, fish or powershell to print exports for eval")
+	restartExpired := flags.Bool("restart-expired", false, "request a new code without asking when the code expires")
+	noResume := flags.Bool("no-resume", false, "do not resume polling the code of an interrupted login")
+	force := flags.Bool("force", false, "run the device flow even if GITHUB_TOKEN, GH_TOKEN (GH_ENTERPRISE_TOKEN for other hosts) or the stored token is still valid")
+	if err := flags.Parse(args); err != nil {
 		return err
 	}
 
-	// Step 2: Prompt the user to enter the user code in a browser
-	fmt.Printf("Open %s in your browser and enter this code:\n", dcResp.VerificationURI)
-	fmt.Println(dcResp.UserCode)
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+	apiUrl := st.apiUrl
+	provider, err := st.provider()
 	if err != nil {
 		return err
 	}
-
-	// Step 3: App polls GitHub to check if the user authorized the device
-	interval := time.Duration(dcResp.Interval+1) * time.Second
-	expiresAt := deviceCodeRequestTime.Add(time.Duration(dcResp.ExpiresIn) * time.Second)
-	acResp, err := pollAccessToken(dcResp.DeviceCode, interval, expiresAt)
+	store, err := st.tokenStore(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Println("access token:", acResp.AccessToken)
+	if provider.Name != "github" && (*printLogin || *auditLog || *org != "" || *gitCredentials) {
+		return errors.New("-print-login, -syslog, -org and -git-credentials are only supported with the github provider")
+	}
+	flow := st.newFlow(provider, strings.Join(deviceflow.ParseScopes(*st.scope), " "))
+	flow.DeviceCodeAccept = *deviceCodeAccept
+	flow.AccessTokenAccept = *accessTokenAccept
+	// machine readable output has no branding
+	if *output == "text" {
+		flow.Banner = *banner
+	}
+
+	if *checkNetwork {
+		return checkDeviceCodeEndpoint(ctx, flow.Provider.DeviceCodeUrl)
+	}
+
+	if *showScopes {
+		requested := strings.Join(deviceflow.ParseScopes(*st.scope), " ")
+		if requested == "" {
+			fmt.Println(`"" (read-only access to public information)`)
+		} else {
+			fmt.Println(requested)
+		}
+		return nil
+	}
+
+	if *hyperlinks != "auto" && *hyperlinks != "always" && *hyperlinks != "never" {
+		return fmt.Errorf("invalid -hyperlinks value: %s", *hyperlinks)
+	}
+
+	switch *output {
+	case "text", "json", "shell", "fish", "powershell":
+	default:
+		return fmt.Errorf("invalid -output value: %s", *output)
+	}
+	if *output != "text" && (*printLogin || *once || *tokenFd > 0 || *shell) {
+		return fmt.Errorf("-output %s cannot be used with -print-login, -once, -token-fd or -shell", *output)
+	}
+
+	if *once {
+		*org, *authorizationHeaderFile, *auditLog, *notify, *shell, *printLogin, *gitCredentials = "", "", false, false, false, false, false
+		// no reuse of existing tokens, no browser, clipboard or flow state file either
+		*force, *noResume, *noBrowser = true, true, true
+		*copyCode, *qrCode, *tui = false, false, false
+	}
+
+	// prompts and progress always go to stderr, stdout only gets the result
+	// so that TOKEN=$(gh-device login) works
+	out := os.Stderr
+
+	if *maxTotalRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, *maxTotalRuntime, errMaxTotalRuntime)
+		defer cancel()
+		// the deadline of a single request is not the ceiling of the whole run
+		defer func() {
+			if err != nil && errors.Is(context.Cause(ctx), errMaxTotalRuntime) {
+				err = &exitError{code: exitTimeout, msg: fmt.Sprintf("maximum total runtime of %s exceeded", *maxTotalRuntime)}
+			}
+		}()
+	}
+
+	// https://docs.github.com/ja/developers/apps/building-oauth-apps/authorizing-oauth-apps#device-flow
+
+	if unknown := provider.UnknownScopes(deviceflow.ParseScopes(*st.scope)); len(unknown) > 0 {
+		logger.Warn("unknown scopes are requested", "scopes", strings.Join(unknown, ", "))
+	}
+
+	if *st.clientId == "" {
+		return errors.New("client ID is required, use -client-id")
+	}
+
+	var acResp *deviceflow.AccessTokenResponse
+	var refreshed bool
+	if !*force && provider.Name == "github" {
+		acResp, refreshed = validToken(ctx, st, store, deviceflow.ParseScopes(*st.scope))
+	}
+	reused := acResp != nil
+	if reused {
+		logger.Info("using an existing valid token, pass -force to authenticate again")
+	} else {
+		p := &prompter{
+			out:                 out,
+			compact:             *compact,
+			hyperlinks:          *hyperlinks,
+			notify:              *notify,
+			qr:                  *qrCode,
+			openBrowser:         !*noBrowser,
+			copyCode:            *copyCode,
+			tui:                 *tui,
+			json:                *output == "json",
+			autoRestart:         *restartExpired,
+			resume:              !*noResume,
+			waitMessage:         *waitMessage,
+			waitMessageInterval: *waitMessageInterval,
+		}
+		acResp, err = authenticate(ctx, flow, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *once {
+		fmt.Println(acResp.AccessToken)
+		return nil
+	}
+
+	// a freshly authorized token is occasionally rejected by an immediate API call,
+	// including the /user call of saving to the gh store
+	if !reused && *grace > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*grace):
+		}
+	}
+
+	if store != nil && !reused {
+		if err := store.save(*st.host, *st.clientId, newStoredToken(acResp)); err != nil {
+			return err
+		}
+	}
+	if *printLogin {
+		u, err := getUser(ctx, apiUrl, acResp.AccessToken)
+		if err != nil {
+			return err
+		}
+		fmt.Println(u.Login)
+	} else if *tokenFd > 0 {
+		f := os.NewFile(uintptr(*tokenFd), "token-fd")
+		if _, err := fmt.Fprintln(f, acResp.AccessToken); err != nil {
+			return err
+		}
+		f.Close()
+	} else if *output == "json" {
+		if err := writeJSON(os.Stdout, newTokenEvent(acResp)); err != nil {
+			return err
+		}
+	} else if *output != "text" {
+		vars := map[string]string{"GITHUB_TOKEN": acResp.AccessToken}
+		if *shellGhToken {
+			vars["GH_TOKEN"] = acResp.AccessToken
+		}
+		fmt.Print(shellExports(*output, vars))
+	} else if !*shell {
+		fmt.Println(acResp.AccessToken)
+	}
+
+	// a reused token was recorded when it was issued, a refreshed one is new
+	if *auditLog && (!reused || refreshed) {
+		u, err := getUser(ctx, apiUrl, acResp.AccessToken)
+		if err != nil {
+			return err
+		}
+		if err := writeAuditLog(u.Login, strings.Join(deviceflow.ParseScopes(acResp.Scope), " "), fingerprint(acResp.AccessToken)); err != nil {
+			return err
+		}
+	}
+
+	if *authorizationHeaderFile != "" {
+		line := "Authorization: " + acResp.AuthorizationHeader() + "\n"
+		if err := ioutil.WriteFile(*authorizationHeaderFile, []byte(line), 0600); err != nil {
+			return err
+		}
+	}
+
+	if *gitCredentials {
+		if err := writeGitCredentials(*st.host, acResp.AccessToken); err != nil {
+			return err
+		}
+	}
+
+	if *org != "" {
+		if err := checkOrgSSO(ctx, out, apiUrl, acResp.AccessToken, *org); err != nil {
+			return err
+		}
+	}
+
+	if *shell {
+		return runShell(acResp.AccessToken, *shellGhToken)
+	}
 
 	return nil
 }
 
+// https://git-scm.com/docs/git-credential-store#_storage_format
+// an existing line for the host is replaced rather than duplicated
+func writeGitCredentials(host, accessToken string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(home, ".git-credentials")
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+		if u, err := url.Parse(line); err == nil && u.Host == host {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	u := &url.URL{Scheme: "https", User: url.UserPassword("x-access-token", accessToken), Host: host}
+	lines = append(lines, u.String())
+
+	return ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
+
+func runShell(accessToken string, ghToken bool) error {
+	sh := os.Getenv("SHELL")
+	if sh == "" {
+		sh = "/bin/sh"
+	}
+	cmd := exec.Command(sh)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GITHUB_TOKEN="+accessToken)
+	if ghToken {
+		cmd.Env = append(cmd.Env, "GH_TOKEN="+accessToken)
+	}
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// exit with the code of the last command in the shell
+		return &exitError{code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+const (
+	// exit code when interrupted, as shells report for SIGINT
+	exitInterrupted = 130
+
+	// exit code when -max-total-runtime is exceeded, as timeout(1) reports
+	exitTimeout = 124
+)
+
+// exitError makes main exit with code after printing the message, other errors exit with 1
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string {
+	return e.msg
+}
+
 func main() {
-	if err := run(os.Args); err != nil {
-		panic(err)
+	// in-flight requests and polling are canceled on Ctrl-C or SIGTERM,
+	// a second signal kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	// NotifyContext keeps trapping the signals until stop, which would swallow the second one
+	// while a prompt such as [Y/n] or the passphrase blocks on stdin
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	shutdownTracing, err := setupTracing(ctx)
+	if err == nil {
+		err = run(ctx, os.Args)
+	}
+	stop()
+	// spans are flushed even if the run was interrupted
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if ferr := shutdownTracing(flushCtx); ferr != nil {
+		logger.Warn("failed to export traces", "error", ferr)
+	}
+	cancel()
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "interrupted")
+		os.Exit(exitInterrupted)
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		if exitErr.msg != "" {
+			fmt.Fprintln(os.Stderr, exitErr.msg)
+		}
+		os.Exit(exitErr.code)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
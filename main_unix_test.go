@@ -0,0 +1,163 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// fakeCommands puts scripts named names in front of PATH,
+// each one records its arguments to a file of the same name in the returned directory
+func fakeCommands(t *testing.T, names ...string) string {
+	t.Helper()
+	bin := t.TempDir()
+	for _, name := range names {
+		script := "#!/bin/sh\necho \"$@\" >> '" + filepath.Join(bin, name+".log") + "'\ncat > /dev/null\n"
+		if err := ioutil.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return bin
+}
+
+func TestLoginOnce(t *testing.T) {
+	home := setupEnv(t)
+	s := newFakeGitHub(t)
+	bin := fakeCommands(t, "xdg-open", "wl-copy", "xclip", "xsel", "notify-send")
+	t.Setenv("DISPLAY", ":0")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "ghe_env")
+	t.Setenv("GITHUB_OAUTH_STORE_PASSPHRASE", "passphrase")
+	header := filepath.Join(home, "header")
+
+	args := []string{
+		"-config", s.config, "-host", "127.0.0.1", "-client-id", "cid", "-once",
+		"-store", "file", "-copy-code", "-notify", "-git-credentials", "-print-login", "-authorization-header-file", header,
+	}
+	stdout, _, err := login(t, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != testToken+"\n" {
+		t.Errorf("stdout = %q, want only a new token", stdout)
+	}
+	if len(s.userTokens) != 0 {
+		t.Errorf("user requests = %d, want no API calls", len(s.userTokens))
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(bin, "*.log"))
+	for _, l := range logs {
+		t.Errorf("%s was run", strings.TrimSuffix(filepath.Base(l), ".log"))
+	}
+	for _, path := range []string{
+		header,
+		filepath.Join(home, ".git-credentials"),
+		filepath.Join(home, ".config", "gh-device"),
+		filepath.Join(home, ".cache", "gh-device"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s was written", path)
+		}
+	}
+}
+
+func TestLoginNotify(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("notify-send is only used on linux")
+	}
+	setupEnv(t)
+	s := newFakeGitHub(t)
+	bin := fakeCommands(t, "notify-send")
+
+	if _, _, err := login(t, s.loginArgs("-notify")...); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(bin, "notify-send.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Enter ABCD-1234 Open https://127.0.0.1/login/device in your browser\n"; string(b) != want {
+		t.Errorf("notify-send args = %q, want %q", b, want)
+	}
+}
+
+func TestLoginTokenFd(t *testing.T) {
+	setupEnv(t)
+	s := newFakeGitHub(t)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// runLogin closes the descriptor it is given
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	stdout, stderr, err := login(t, s.loginArgs("-token-fd", strconv.Itoa(fd))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testToken+"\n" {
+		t.Errorf("token fd = %q, want the token", b)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing", stdout)
+	}
+	if !strings.Contains(stderr, testUserCode) {
+		t.Errorf("stderr = %q, want the prompt", stderr)
+	}
+}
+
+func TestRunShell(t *testing.T) {
+	dir := t.TempDir()
+	env := filepath.Join(dir, "env")
+	sh := filepath.Join(dir, "sh")
+	script := "#!/bin/sh\necho \"$GITHUB_TOKEN $GH_TOKEN\" > '" + env + "'\nexit 3\n"
+	if err := ioutil.WriteFile(sh, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", sh)
+	t.Setenv("GH_TOKEN", "")
+
+	err := runShell(testToken, false)
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Errorf("err = %v, want the exit code of the shell", err)
+	}
+	if b, _ := ioutil.ReadFile(env); string(b) != testToken+" \n" {
+		t.Errorf("environment = %q, want only GITHUB_TOKEN", b)
+	}
+
+	if err := runShell(testToken, true); !errors.As(err, &exitErr) {
+		t.Errorf("err = %v", err)
+	}
+	if b, _ := ioutil.ReadFile(env); string(b) != testToken+" "+testToken+"\n" {
+		t.Errorf("environment = %q, want GITHUB_TOKEN and GH_TOKEN", b)
+	}
+}
+
+func TestAuditMessage(t *testing.T) {
+	msg := auditMessage("octocat", "read:org repo", fingerprint(testToken))
+	if want := `access token issued: login=octocat scope="read:org repo" fingerprint=` + fingerprint(testToken); msg != want {
+		t.Errorf("auditMessage = %q, want %q", msg, want)
+	}
+	if strings.Contains(msg, testToken) {
+		t.Errorf("auditMessage = %q, contains the token", msg)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// events written with -output json, one JSON document per line
+
+type deviceCodeEvent struct {
+	Event                   string    `json:"event"`
+	UserCode                string    `json:"user_code"`
+	VerificationURI         string    `json:"verification_uri"`
+	VerificationURIComplete string    `json:"verification_uri_complete,omitempty"`
+	ExpiresAt               time.Time `json:"expires_at"`
+}
+
+type tokenEvent struct {
+	Event       string     `json:"event"`
+	AccessToken string     `json:"access_token"`
+	TokenType   string     `json:"token_type"`
+	Scope       string     `json:"scope"`
+	GrantedAt   time.Time  `json:"granted_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+func newTokenEvent(acResp *deviceflow.AccessTokenResponse) *tokenEvent {
+	now := time.Now()
+	ev := &tokenEvent{
+		Event:       "token",
+		AccessToken: acResp.AccessToken,
+		TokenType:   acResp.TokenType,
+		Scope:       acResp.Scope,
+		GrantedAt:   now,
+	}
+	if acResp.ExpiresIn > 0 {
+		expiresAt := now.Add(time.Duration(acResp.ExpiresIn) * time.Second)
+		ev.ExpiresAt = &expiresAt
+	}
+	return ev
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// shellExports returns the statements setting the variables in the syntax of -output,
+// for eval "$(gh-device login -output shell)"
+func shellExports(output string, vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		v := vars[name]
+		switch output {
+		case "fish":
+			v = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v)
+			fmt.Fprintf(&b, "set -gx %s '%s';\n", name, v)
+		case "powershell":
+			fmt.Fprintf(&b, "$env:%s = '%s'\n", name, strings.ReplaceAll(v, "'", "''"))
+		default:
+			fmt.Fprintf(&b, "export %s='%s'\n", name, strings.ReplaceAll(v, "'", `'\''`))
+		}
+	}
+	return b.String()
+}
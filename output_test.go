@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+func TestShellExports(t *testing.T) {
+	vars := map[string]string{"GITHUB_TOKEN": "it's", "GH_TOKEN": `a\b`}
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"shell", "export GH_TOKEN='a\\b'\nexport GITHUB_TOKEN='it'\\''s'\n"},
+		{"fish", "set -gx GH_TOKEN 'a\\\\b';\nset -gx GITHUB_TOKEN 'it\\'s';\n"},
+		{"powershell", "$env:GH_TOKEN = 'a\\b'\n$env:GITHUB_TOKEN = 'it''s'\n"},
+	}
+	for _, tt := range tests {
+		if got := shellExports(tt.output, vars); got != tt.want {
+			t.Errorf("shellExports(%s) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestNewTokenEvent(t *testing.T) {
+	ev := newTokenEvent(&deviceflow.AccessTokenResponse{AccessToken: "t", TokenType: "bearer", Scope: "repo"})
+	if ev.Event != "token" || ev.AccessToken != "t" || ev.ExpiresAt != nil {
+		t.Errorf("event = %+v, want no expiry", ev)
+	}
+
+	ev = newTokenEvent(&deviceflow.AccessTokenResponse{AccessToken: "t", ExpiresIn: 3600})
+	if ev.ExpiresAt == nil || ev.ExpiresAt.Sub(ev.GrantedAt) != time.Hour {
+		t.Errorf("expires_at = %v, want an hour after granted_at %v", ev.ExpiresAt, ev.GrantedAt)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// prompter shows the device flow prompts to the user
+type prompter struct {
+	out                 *os.File
+	compact             bool
+	hyperlinks          string
+	notify              bool
+	qr                  bool
+	openBrowser         bool
+	copyCode            bool
+	tui                 bool
+	waitMessage         string
+	waitMessageInterval time.Duration
+	autoRestart         bool
+	resume              bool
+
+	// json writes the prompt as a deviceCodeEvent to stdout instead
+	json bool
+}
+
+// authenticate runs the device flow, prompting the user with p,
+// and starts over with a new code if the code expires before the user enters it
+func authenticate(ctx context.Context, flow *deviceflow.Flow, p *prompter) (*deviceflow.AccessTokenResponse, error) {
+	if flow.Banner != "" && !p.json {
+		fmt.Fprintln(p.out, flow.Banner)
+	}
+	for {
+		acResp, err := authorize(ctx, flow, p)
+		if errors.Is(err, deviceflow.ErrExpiredToken) && p.restartExpired(ctx) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		provider := flow.Provider
+		if acResp.IDToken != "" && provider.Issuer != "" {
+			claims, err := flow.VerifyIDToken(ctx, acResp.IDToken)
+			if err != nil {
+				return nil, fmt.Errorf("failed to verify ID token: %w", err)
+			}
+			logger.Info("id_token_verified", "sub", claims.Subject, "iss", claims.Issuer)
+		}
+		return acResp, nil
+	}
+}
+
+// restartExpired asks whether to request a new code on a terminal,
+// otherwise it follows -restart-expired
+func (p *prompter) restartExpired(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if p.autoRestart {
+		logger.Info("the code has expired, requesting a new one")
+		return true
+	}
+	if p.json || !isTerminal(os.Stdin) || !isTerminal(p.out) {
+		return false
+	}
+	fmt.Fprint(p.out, "The code has expired. Request a new one? [Y/n] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+// authorize runs steps 1 to 3 of the device flow once
+//
+// https://docs.github.com/ja/developers/apps/building-oauth-apps/authorizing-oauth-apps#device-flow
+func authorize(ctx context.Context, flow *deviceflow.Flow, p *prompter) (*deviceflow.AccessTokenResponse, error) {
+	out := p.out
+
+	// Step 1: App requests the device and user verification codes from GitHub,
+	// unless the code of an interrupted run is still valid
+	var dcResp *deviceflow.DeviceCodeResponse
+	var expiresAt time.Time
+	statePath := flowStatePath(flow)
+	var state *flowState
+	if p.resume {
+		state = loadFlowState(statePath)
+	}
+	if state != nil {
+		dcResp, expiresAt = state.DeviceCode, state.ExpiresAt
+		logger.Info("resuming the previous login, the code is still valid")
+	} else {
+		deviceCodeRequestTime := time.Now()
+		var err error
+		dcResp, err = flow.RequestDeviceCode(ctx)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt = deviceCodeRequestTime.Add(time.Duration(dcResp.ExpiresIn) * time.Second)
+	}
+	if p.resume {
+		if err := saveFlowState(statePath, &flowState{DeviceCode: dcResp, ExpiresAt: expiresAt}); err != nil {
+			logger.Warn("failed to save the flow state", "error", err)
+		}
+	}
+
+	// Step 2: Prompt the user to enter the user code in a browser
+	verificationURI := dcResp.VerificationURI
+	if p.hyperlinks == "always" || (p.hyperlinks == "auto" && isTerminal(out)) {
+		verificationURI = hyperlink(verificationURI, verificationURI)
+	}
+	useTUI := p.tui && !p.compact && !p.json && canRenderQR(out)
+	if p.json {
+		ev := &deviceCodeEvent{
+			Event:                   "device_code",
+			UserCode:                dcResp.UserCode,
+			VerificationURI:         dcResp.VerificationURI,
+			VerificationURIComplete: dcResp.VerificationURIComplete,
+			ExpiresAt:               expiresAt,
+		}
+		if err := writeJSON(os.Stdout, ev); err != nil {
+			return nil, err
+		}
+	} else if useTUI {
+		// the login screen shows the prompt
+	} else if p.compact {
+		fmt.Fprintf(out, "Authorize at %s (code: %s)\n", verificationURI, dcResp.UserCode)
+		fmt.Fprint(out, "Waiting for authorization...")
+	} else if dcResp.Message != "" {
+		fmt.Fprintln(out, dcResp.Message)
+	} else {
+		fmt.Fprintf(out, "Open %s in your browser and enter this code:\n", verificationURI)
+		fmt.Fprintln(out, dcResp.UserCode)
+	}
+	if p.qr && !p.compact && !p.json && !useTUI && canRenderQR(out) {
+		uri := dcResp.VerificationURIComplete
+		if uri == "" {
+			uri = dcResp.VerificationURI
+		}
+		if err := renderQR(out, uri); err != nil {
+			return nil, err
+		}
+	}
+	if p.copyCode {
+		if err := copyToClipboard(dcResp.UserCode); err != nil {
+			logger.Warn("failed to copy the code to the clipboard", "error", err)
+		}
+	}
+	if p.openBrowser && hasDesktop() {
+		uri := dcResp.VerificationURIComplete
+		if uri == "" {
+			uri = dcResp.VerificationURI
+		}
+		if err := openURL(uri); err != nil {
+			logger.Warn("failed to open the browser", "error", err)
+		}
+	}
+	if p.notify {
+		sendNotification("Enter "+dcResp.UserCode, "Open "+dcResp.VerificationURI+" in your browser")
+	}
+
+	// Step 3: App polls GitHub to check if the user authorized the device
+	interval := time.Duration(dcResp.Interval+1) * time.Second
+	var screen *loginScreen
+	if useTUI {
+		screen = newLoginScreen(out, flow.Provider.Name, dcResp, expiresAt)
+		screen.start()
+	}
+	wait := &waitMessages{interval: p.waitMessageInterval, last: time.Now()}
+	flow.OnPending = func() {
+		if screen != nil {
+			screen.pending()
+			return
+		}
+		if !p.compact && !p.json && isTerminal(out) && wait.due(time.Now()) {
+			fmt.Fprintln(out, p.waitMessage)
+		}
+	}
+	acResp, err := flow.PollAccessToken(ctx, dcResp.DeviceCode, interval, expiresAt)
+	if screen != nil {
+		screen.stop()
+	}
+	// the code is kept for the next run only if this one was interrupted
+	if p.resume && !errors.Is(err, context.Canceled) {
+		os.Remove(statePath)
+	}
+	if err != nil {
+		if p.compact {
+			fmt.Fprintln(out)
+		}
+		return nil, err
+	}
+	if p.compact && isTerminal(out) {
+		// overwrite the waiting line
+		fmt.Fprint(out, "\r\033[KAuthorized.\n")
+	} else if p.compact {
+		// captured output gets no escape sequences
+		fmt.Fprint(out, "\nAuthorized.\n")
+	}
+	return acResp, nil
+}
+
+// waitMessages paces the -wait-message reminders
+type waitMessages struct {
+	interval time.Duration
+	last     time.Time
+}
+
+// due reports whether a message is due at now, and if so counts it as printed
+func (w *waitMessages) due(now time.Time) bool {
+	if w.interval <= 0 || now.Sub(w.last) < w.interval {
+		return false
+	}
+	w.last = now
+	return true
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
+func hyperlink(uri, text string) string {
+	return "\x1b]8;;" + uri + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// hasDesktop reports whether a browser can be opened, i.e. not in an SSH session or a headless Linux
+func hasDesktop() bool {
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "" {
+		return false
+	}
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+func openURL(uri string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", uri)
+	case "windows":
+		// the empty argument is the window title, otherwise a quoted URI would be taken as the title
+		cmd = exec.Command("cmd", "/c", "start", "", uri)
+	default:
+		cmd = exec.Command("xdg-open", uri)
+	}
+	return cmd.Start()
+}
+
+// copyToClipboard uses the first clipboard command found in PATH
+func copyToClipboard(text string) error {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		candidates = [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard command is available")
+}
+
+// sendNotification is best effort, nothing happens if no notifier is available
+func sendNotification(title, message string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", message, title))
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("notify-send", title, message)
+	default:
+		return
+	}
+	_ = cmd.Run()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitMessagesDue(t *testing.T) {
+	start := time.Now()
+	w := &waitMessages{interval: 30 * time.Second, last: start}
+	tests := []struct {
+		after time.Duration
+		want  bool
+	}{
+		{10 * time.Second, false},
+		{30 * time.Second, true},
+		{45 * time.Second, false},
+		{61 * time.Second, true},
+		{62 * time.Second, false},
+	}
+	for _, tt := range tests {
+		if got := w.due(start.Add(tt.after)); got != tt.want {
+			t.Errorf("due after %s = %v, want %v", tt.after, got, tt.want)
+		}
+	}
+
+	disabled := &waitMessages{last: start}
+	if disabled.due(start.Add(time.Hour)) {
+		t.Error("due with a zero interval = true, want false")
+	}
+}
+
+func TestHyperlink(t *testing.T) {
+	got := hyperlink("https://github.com/login/device", "github.com/login/device")
+	want := "\x1b]8;;https://github.com/login/device\x1b\\github.com/login/device\x1b]8;;\x1b\\"
+	if got != want {
+		t.Errorf("hyperlink = %q, want %q", got, want)
+	}
+}
+
+func TestHasDesktop(t *testing.T) {
+	setupEnv(t)
+	t.Setenv("DISPLAY", ":0")
+	t.Setenv("SSH_CONNECTION", "10.0.0.1 22 10.0.0.2 22")
+	if hasDesktop() {
+		t.Error("hasDesktop in an SSH session = true, want false")
+	}
+}
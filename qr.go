@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// quiet zone around the code, in modules
+const qrMargin = 2
+
+// canRenderQR reports whether f is a terminal that can draw the block characters
+func canRenderQR(f *os.File) bool {
+	return isTerminal(f) && os.Getenv("TERM") != "dumb"
+}
+
+// renderQR draws text as a QR code with half block characters, two modules per line.
+// Light modules are drawn, so the code scans on dark terminal backgrounds.
+func renderQR(w io.Writer, text string) error {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return err
+	}
+	light := func(x, y int) bool { return !code.Black(x, y) }
+
+	var b strings.Builder
+	for y := -qrMargin; y < code.Size+qrMargin; y += 2 {
+		for x := -qrMargin; x < code.Size+qrMargin; x++ {
+			top, bottom := light(x, y), light(x, y+1) && y+1 < code.Size+qrMargin
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
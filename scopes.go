@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// runScopes exits with 1 listing the missing scopes if the stored token lacks any of -require,
+// e.g. to check permissions in a script before doing work
+func runScopes(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("scopes", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	require := flags.String("require", "", "scopes the token must have, separated by commas or spaces")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	t, err := loadValidToken(ctx, st)
+	if err != nil {
+		return err
+	}
+	resp, _, err := get(ctx, st.apiUrl+"/user", t.AccessToken)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the stored token is not valid: %s", resp.Status)
+	}
+	granted, ok := grantedScopes(resp)
+	if !ok {
+		return &exitError{code: 2, msg: "the token does not report scopes, e.g. a GitHub App user token"}
+	}
+
+	if *require == "" {
+		fmt.Println(strings.Join(granted, " "))
+		return nil
+	}
+	if missing := deviceflow.MissingScopes(granted, deviceflow.ParseScopes(*require)); len(missing) > 0 {
+		return &exitError{code: 1, msg: "missing scopes: " + strings.Join(missing, ", ")}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// default hosts of the providers selectable with -provider,
+// gitea has no default and requires -host
+var providerHosts = map[string]string{
+	"github":    "github.com",
+	"gitlab":    "gitlab.com",
+	"google":    "oauth2.googleapis.com",
+	"microsoft": "login.microsoftonline.com",
+}
+
+func newProvider(name, host, tenant, clientId, clientSecret string) (deviceflow.Provider, error) {
+	switch name {
+	case "github":
+		return deviceflow.GitHub(host, clientId), nil
+	case "gitlab":
+		return deviceflow.GitLab(host, clientId), nil
+	case "gitea":
+		if host == "" {
+			return deviceflow.Provider{}, errors.New("-host is required for the gitea provider")
+		}
+		return deviceflow.Gitea(host, clientId), nil
+	case "google":
+		return deviceflow.Google(clientId, clientSecret), nil
+	case "microsoft":
+		return deviceflow.Microsoft(tenant, clientId), nil
+	}
+	return deviceflow.Provider{}, fmt.Errorf("unknown provider: %s", name)
+}
+
+// https://docs.github.com/en/enterprise-server/rest/overview/resources-in-the-rest-api#current-version
+func apiUrlForHost(host string) string {
+	if host == "github.com" {
+		return "https://api.github.com"
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// settings are shared by the commands,
+// flags and environment variables take precedence over the config file
+type settings struct {
+	configPath   *string
+	providerName *string
+	tenant       *string
+	host         *string
+	clientId     *string
+	clientSecret *string
+	scope        *string
+	store        *string
+	proxy        *string
+	socks5       *string
+	caFile       *string
+	minTLS       *string
+	clientCert   *string
+	clientKey    *string
+	timeout      *time.Duration
+	maxAttempts  *int
+	debug        *bool
+	logLevel     *string
+	logFormat    *string
+
+	hostConfig hostConfig
+	apiUrl     string
+}
+
+func addSettingsFlags(flags *flag.FlagSet) *settings {
+	return &settings{
+		configPath:   flags.String("config", defaultConfigPath(), "config file defining settings per host"),
+		providerName: flags.String("provider", "github", "device flow provider: github, gitlab, gitea, google or microsoft"),
+		tenant:       flags.String("tenant", "common", "tenant of the microsoft provider"),
+		host:         flags.String("host", envOr("GITHUB_OAUTH_HOST", ""), "hostname of the provider, e.g. a GitHub Enterprise Server, also selects the config file section (env GITHUB_OAUTH_HOST)"),
+		clientId:     flags.String("client-id", envOr("GITHUB_OAUTH_CLIENT_ID", oauthClientId), "client ID of the OAuth app (env GITHUB_OAUTH_CLIENT_ID)"),
+		clientSecret: flags.String("client-secret", envOr("GITHUB_OAUTH_CLIENT_SECRET", ""), "client secret, required by some providers such as google (env GITHUB_OAUTH_CLIENT_SECRET)"),
+		scope:        flags.String("scope", envOr("GITHUB_OAUTH_SCOPES", defaultScope), "scopes to request, separated by spaces or commas (env GITHUB_OAUTH_SCOPES)"),
+		store:        flags.String("store", "", "where to store the access token: keyring, file (encrypted with a passphrase), gh (GitHub CLI hosts.yml), or empty to not store it"),
+		proxy:        flags.String("proxy", "", "URL of the proxy for all requests, e.g. http://proxy.example.com:8080 (default from HTTPS_PROXY, HTTP_PROXY and NO_PROXY)"),
+		socks5:       flags.String("socks5", "", "SOCKS5 proxy for all requests as [user:password@]host:port, e.g. localhost:1080 for ssh -D"),
+		caFile:       flags.String("ca-file", "", "PEM file of CA certificates trusted in addition to the system roots"),
+		minTLS:       flags.String("min-tls", "", "minimum TLS version: 1.0, 1.1, 1.2 or 1.3 (default 1.2)"),
+		clientCert:   flags.String("client-cert", "", "PEM file of the TLS client certificate, for gateways requiring mutual TLS"),
+		clientKey:    flags.String("client-key", "", "PEM file of the private key of -client-cert"),
+		timeout:      flags.Duration("timeout", 30*time.Second, "timeout of each HTTP request, 0 disables it"),
+		maxAttempts:  flags.Int("max-attempts", 3, "tries of each device flow request on network errors and 5xx responses"),
+		debug:        flags.Bool("debug", false, "print connection timings and redacted dumps of all requests to stderr"),
+		logLevel:     flags.String("log-level", envOr("GITHUB_OAUTH_LOG_LEVEL", "info"), "minimum level of the logs on stderr: debug, info, warn or error (env GITHUB_OAUTH_LOG_LEVEL)"),
+		logFormat:    flags.String("log-format", envOr("GITHUB_OAUTH_LOG_FORMAT", "text"), "format of the logs on stderr: text or json (env GITHUB_OAUTH_LOG_FORMAT)"),
+	}
+}
+
+// resolve must be called after flags are parsed
+func (s *settings) resolve(flags *flag.FlagSet) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	cfg, err := loadConfig(*s.configPath, set["config"])
+	if err != nil {
+		return err
+	}
+
+	if *s.host == "" {
+		*s.host = providerHosts[*s.providerName]
+	}
+	hc := cfg.Hosts[*s.host]
+	s.hostConfig = hc
+
+	s.apiUrl = apiUrlForHost(*s.host)
+	if hc.ApiUrl != "" {
+		s.apiUrl = hc.ApiUrl
+	}
+	if _, ok := os.LookupEnv("GITHUB_OAUTH_CLIENT_ID"); !set["client-id"] && !ok && hc.ClientId != "" {
+		*s.clientId = hc.ClientId
+	}
+	if _, ok := os.LookupEnv("GITHUB_OAUTH_CLIENT_SECRET"); !set["client-secret"] && !ok && hc.ClientSecret != "" {
+		*s.clientSecret = hc.ClientSecret
+	}
+	if !set["tenant"] && hc.Tenant != "" {
+		*s.tenant = hc.Tenant
+	}
+	if _, ok := os.LookupEnv("GITHUB_OAUTH_SCOPES"); !set["scope"] && !ok && hc.Scope != "" {
+		*s.scope = hc.Scope
+	}
+	if !set["store"] && hc.Store != "" {
+		*s.store = hc.Store
+	}
+	if !set["proxy"] && hc.Proxy != "" {
+		*s.proxy = hc.Proxy
+	}
+	if !set["socks5"] && hc.Socks5 != "" {
+		*s.socks5 = hc.Socks5
+	}
+	if !set["ca-file"] && hc.CaFile != "" {
+		*s.caFile = hc.CaFile
+	}
+	if !set["min-tls"] && hc.MinTLS != "" {
+		*s.minTLS = hc.MinTLS
+	}
+	if !set["client-cert"] && hc.ClientCert != "" {
+		*s.clientCert = hc.ClientCert
+	}
+	if !set["client-key"] && hc.ClientKey != "" {
+		*s.clientKey = hc.ClientKey
+	}
+
+	logger, err = newLogger(*s.logLevel, *s.logFormat)
+	if err != nil {
+		return err
+	}
+	httpClient, err = s.newHTTPClient()
+	return err
+}
+
+func (s *settings) provider() (deviceflow.Provider, error) {
+	provider, err := newProvider(*s.providerName, *s.host, *s.tenant, *s.clientId, *s.clientSecret)
+	if err != nil {
+		return provider, err
+	}
+	hc := s.hostConfig
+	if hc.DeviceCodeUrl != "" {
+		provider.DeviceCodeUrl = hc.DeviceCodeUrl
+	}
+	if hc.AccessTokenUrl != "" {
+		provider.AccessTokenUrl = hc.AccessTokenUrl
+	}
+	if hc.Issuer != "" {
+		provider.Issuer = hc.Issuer
+	}
+	return provider, nil
+}
+
+func (s *settings) newFlow(provider deviceflow.Provider, scope string) *deviceflow.Flow {
+	return deviceflow.New(provider, scope,
+		deviceflow.WithHTTPClient(httpClient),
+		deviceflow.WithRequestTimeout(*s.timeout),
+		deviceflow.WithMaxAttempts(*s.maxAttempts),
+		deviceflow.WithLogger(logger),
+	)
+}
+
+func (s *settings) tokenStore(ctx context.Context) (tokenStore, error) {
+	return newTokenStore(ctx, *s.store, s.apiUrl)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newSettings parses args like the commands do, without resolving them
+func newSettings(t *testing.T, args ...string) (*settings, *flag.FlagSet) {
+	t.Helper()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return st, flags
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolve(t *testing.T) {
+	setupEnv(t)
+	config := writeConfig(t, `[hosts."ghe.example.com"]
+client_id = "config-id"
+scope = "repo"
+api_url = "https://api.ghe.example.com"
+device_code_url = "https://ghe.example.com/device"
+`)
+
+	st, flags := newSettings(t, "-config", config, "-host", "ghe.example.com")
+	if err := st.resolve(flags); err != nil {
+		t.Fatal(err)
+	}
+	if *st.clientId != "config-id" || *st.scope != "repo" || st.apiUrl != "https://api.ghe.example.com" {
+		t.Errorf("settings = %s %s %s, want the config file values", *st.clientId, *st.scope, st.apiUrl)
+	}
+	provider, err := st.provider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.DeviceCodeUrl != "https://ghe.example.com/device" || provider.AccessTokenUrl != "https://ghe.example.com/login/oauth/access_token" {
+		t.Errorf("provider = %+v, want the device code URL of the config file", provider)
+	}
+
+	t.Setenv("GITHUB_OAUTH_SCOPES", "gist")
+	st, flags = newSettings(t, "-config", config, "-host", "ghe.example.com", "-client-id", "flag-id")
+	if err := st.resolve(flags); err != nil {
+		t.Fatal(err)
+	}
+	if *st.clientId != "flag-id" || *st.scope != "gist" {
+		t.Errorf("settings = %s %s, want the flag and the environment to take precedence", *st.clientId, *st.scope)
+	}
+}
+
+func TestResolveDefaults(t *testing.T) {
+	setupEnv(t)
+	st, flags := newSettings(t, "-config", filepath.Join(t.TempDir(), "missing.toml"))
+	err := st.resolve(flags)
+	if err == nil {
+		t.Error("resolve with a missing -config = nil, want an error")
+	}
+
+	st, flags = newSettings(t)
+	if err := st.resolve(flags); err != nil {
+		t.Fatal(err)
+	}
+	if *st.host != "github.com" || st.apiUrl != "https://api.github.com" {
+		t.Errorf("host = %s, api = %s", *st.host, st.apiUrl)
+	}
+
+	st, flags = newSettings(t, "-provider", "gitlab")
+	if err := st.resolve(flags); err != nil {
+		t.Fatal(err)
+	}
+	if *st.host != "gitlab.com" {
+		t.Errorf("host = %s, want gitlab.com", *st.host)
+	}
+}
+
+// resolveError returns the error of resolving the settings of args
+func resolveError(t *testing.T, args ...string) error {
+	t.Helper()
+	// a failed resolve leaves no client behind
+	savedLogger, savedClient := logger, httpClient
+	t.Cleanup(func() { logger, httpClient = savedLogger, savedClient })
+	st, flags := newSettings(t, args...)
+	return st.resolve(flags)
+}
+
+func TestResolveProxy(t *testing.T) {
+	setupEnv(t)
+	if err := resolveError(t, "-proxy", "proxy"); err == nil || !strings.Contains(err.Error(), "invalid proxy URL") {
+		t.Errorf("err = %v, want an invalid proxy URL", err)
+	}
+	if err := resolveError(t, "-proxy", "http://proxy:8080"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResolveSocks5(t *testing.T) {
+	setupEnv(t)
+	if err := resolveError(t, "-proxy", "http://proxy:8080", "-socks5", "localhost:1080"); err == nil || !strings.Contains(err.Error(), "cannot be used together") {
+		t.Errorf("err = %v, want -proxy and -socks5 to conflict", err)
+	}
+	if err := resolveError(t, "-socks5", "localhost"); err == nil || !strings.Contains(err.Error(), "invalid SOCKS5 proxy") {
+		t.Errorf("err = %v, want an invalid SOCKS5 proxy", err)
+	}
+	if err := resolveError(t, "-socks5", "user:pass@localhost:1080"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResolveMinTLS(t *testing.T) {
+	setupEnv(t)
+	if err := resolveError(t, "-min-tls", "1.4"); err == nil || !strings.Contains(err.Error(), "invalid minimum TLS version") {
+		t.Errorf("err = %v, want an invalid TLS version", err)
+	}
+	if err := resolveError(t, "-min-tls", "1.3"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResolveClientCert(t *testing.T) {
+	setupEnv(t)
+	if err := resolveError(t, "-client-cert", "cert.pem"); err == nil || !strings.Contains(err.Error(), "must be given together") {
+		t.Errorf("err = %v, want -client-key to be required", err)
+	}
+}
+
+func TestApiUrlForHost(t *testing.T) {
+	if got := apiUrlForHost("github.com"); got != "https://api.github.com" {
+		t.Errorf("apiUrlForHost(github.com) = %s", got)
+	}
+	if got := apiUrlForHost("ghe.example.com"); got != "https://ghe.example.com/api/v3" {
+		t.Errorf("apiUrlForHost(ghe.example.com) = %s", got)
+	}
+}
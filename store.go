@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+var errTokenNotFound = errors.New("no stored token, run login first")
+
+// refresh expiring tokens a bit before they actually expire
+const refreshMargin = 5 * time.Minute
+
+type storedToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Scope       string    `json:"scope"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// zero values mean the token does not expire
+	ExpiresAt             time.Time `json:"expires_at,omitempty"`
+	RefreshToken          string    `json:"refresh_token,omitempty"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at,omitempty"`
+}
+
+func newStoredToken(acResp *deviceflow.AccessTokenResponse) *storedToken {
+	now := time.Now()
+	t := &storedToken{
+		AccessToken:  acResp.AccessToken,
+		TokenType:    acResp.TokenType,
+		Scope:        acResp.Scope,
+		CreatedAt:    now,
+		RefreshToken: acResp.RefreshToken,
+	}
+	if acResp.ExpiresIn > 0 {
+		t.ExpiresAt = now.Add(time.Duration(acResp.ExpiresIn) * time.Second)
+	}
+	if acResp.RefreshTokenExpiresIn > 0 {
+		t.RefreshTokenExpiresAt = now.Add(time.Duration(acResp.RefreshTokenExpiresIn) * time.Second)
+	}
+	return t
+}
+
+func (t *storedToken) needsRefresh() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().Add(refreshMargin).After(t.ExpiresAt)
+}
+
+// canRefresh reports whether the refresh token exists and is not about to expire
+func (t *storedToken) canRefresh() bool {
+	if t.RefreshToken == "" {
+		return false
+	}
+	return t.RefreshTokenExpiresAt.IsZero() || time.Now().Add(refreshMargin).Before(t.RefreshTokenExpiresAt)
+}
+
+// tokenStore persists access tokens keyed by host and client ID
+type tokenStore interface {
+	load(host, clientId string) (*storedToken, error)
+	save(host, clientId string, t *storedToken) error
+	erase(host, clientId string) error
+}
+
+// newTokenStore returns nil if name is empty,
+// ctx and apiUrl are used by the gh store to look up the user of a saved token
+func newTokenStore(ctx context.Context, name, apiUrl string) (tokenStore, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "keyring":
+		return keyringStore{}, nil
+	case "file":
+		return &fileStore{path: defaultTokenFilePath()}, nil
+	case "gh":
+		return ghStore{path: defaultGhHostsPath(), ctx: ctx, apiUrl: apiUrl}, nil
+	}
+	return nil, fmt.Errorf("unknown token store: %s", name)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// fileStore stores tokens in a file encrypted with AES-256-GCM,
+// using a key derived from a passphrase with scrypt
+type fileStore struct {
+	path string
+
+	// passphrase is asked once and reused by the reads and writes that follow
+	passphrase []byte
+}
+
+type encryptedFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func defaultTokenFilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gh-device", "tokens.enc")
+}
+
+func tokenKey(host, clientId string) string {
+	return host + " " + clientId
+}
+
+// readPassphrase reads the passphrase from GITHUB_OAUTH_STORE_PASSPHRASE, or prompts on the terminal,
+// asking twice if create is true since a mistyped passphrase would lock the new file
+func (s *fileStore) readPassphrase(create bool) ([]byte, error) {
+	if s.passphrase != nil {
+		return s.passphrase, nil
+	}
+	if p, ok := os.LookupEnv("GITHUB_OAUTH_STORE_PASSPHRASE"); ok {
+		s.passphrase = []byte(p)
+		return s.passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("GITHUB_OAUTH_STORE_PASSPHRASE is required when stdin is not a terminal")
+	}
+	prompt := func(msg string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, msg)
+		p, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return p, err
+	}
+	p, err := prompt("Passphrase for the token file: ")
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		confirm, err := prompt("Repeat the passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(p, confirm) {
+			return nil, errors.New("the passphrases do not match")
+		}
+	}
+	s.passphrase = p
+	return p, nil
+}
+
+// https://pkg.go.dev/golang.org/x/crypto/scrypt#Key
+func deriveKey(passphrase, salt []byte) ([]byte, error) {
+	return scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+}
+
+func (s *fileStore) readAll() (map[string]*storedToken, error) {
+	tokens := make(map[string]*storedToken)
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ef := &encryptedFile{}
+	if err := json.Unmarshal(b, ef); err != nil {
+		return nil, err
+	}
+	passphrase, err := s.readPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, ef.Salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, ef.Nonce, ef.Ciphertext, nil)
+	if err != nil {
+		s.passphrase = nil
+		return nil, errors.New("failed to decrypt the token file, wrong passphrase?")
+	}
+	if err := json.Unmarshal(plain, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *fileStore) writeAll(tokens map[string]*storedToken) error {
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(s.path)
+	passphrase, err := s.readPassphrase(os.IsNotExist(err))
+	if err != nil {
+		return err
+	}
+
+	ef := &encryptedFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(ef.Salt); err != nil {
+		return err
+	}
+	key, err := deriveKey(passphrase, ef.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	ef.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(ef.Nonce); err != nil {
+		return err
+	}
+	ef.Ciphertext = gcm.Seal(nil, ef.Nonce, plain, nil)
+
+	b, err := json.Marshal(ef)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b, 0600)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and renames it,
+// so a crash never leaves a partially written file
+func writeFileAtomic(path string, b []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *fileStore) load(host, clientId string) (*storedToken, error) {
+	tokens, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := tokens[tokenKey(host, clientId)]
+	if !ok {
+		return nil, errTokenNotFound
+	}
+	return t, nil
+}
+
+func (s *fileStore) save(host, clientId string, t *storedToken) error {
+	tokens, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	tokens[tokenKey(host, clientId)] = t
+	return s.writeAll(tokens)
+}
+
+func (s *fileStore) erase(host, clientId string) error {
+	tokens, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	delete(tokens, tokenKey(host, clientId))
+	return s.writeAll(tokens)
+}
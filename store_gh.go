@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ghStore reads and writes tokens in the hosts.yml of GitHub CLI,
+// so tokens are shared with gh. gh keeps one token per host, the client ID is ignored.
+//
+// https://github.com/cli/cli/blob/trunk/internal/config/config.go
+type ghStore struct {
+	path string
+
+	// the user lookup of save follows the cancellation of the command and the API URL of the host
+	ctx    context.Context
+	apiUrl string
+}
+
+// same lookup order as gh
+func defaultGhHostsPath() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI", "hosts.yml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+// hosts are kept as generic maps so keys written by gh survive a save
+func (s ghStore) readAll() (map[string]map[string]interface{}, error) {
+	hosts := make(map[string]map[string]interface{})
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return hosts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, &hosts); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+func (s ghStore) writeAll(hosts map[string]map[string]interface{}) error {
+	b, err := yaml.Marshal(hosts)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b, 0600)
+}
+
+func (s ghStore) load(host, clientId string) (*storedToken, error) {
+	hosts, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	token, _ := hosts[host]["oauth_token"].(string)
+	if token == "" {
+		return nil, errTokenNotFound
+	}
+	return &storedToken{AccessToken: token, TokenType: "bearer"}, nil
+}
+
+func (s ghStore) save(host, clientId string, t *storedToken) error {
+	hosts, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	h := hosts[host]
+	if h == nil {
+		h = make(map[string]interface{})
+		hosts[host] = h
+	}
+	h["oauth_token"] = t.AccessToken
+	if _, ok := h["git_protocol"]; !ok {
+		h["git_protocol"] = "https"
+	}
+	// gh shows the user of each host, look it up if the host is reachable
+	if u, err := getUser(s.ctx, s.apiUrl, t.AccessToken); err == nil {
+		h["user"] = u.Login
+	}
+	return s.writeAll(hosts)
+}
+
+func (s ghStore) erase(host, clientId string) error {
+	hosts, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	delete(hosts, host)
+	return s.writeAll(hosts)
+}
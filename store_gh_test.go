@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGhStore(t *testing.T) {
+	setupEnv(t)
+	path := filepath.Join(t.TempDir(), "hosts.yml")
+	hosts := "github.com:\n    oauth_token: gho_other\n    git_protocol: ssh\n    user: hubot\n"
+	if err := ioutil.WriteFile(path, []byte(hosts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	api := newFakeGitHub(t)
+	s := ghStore{path: path, ctx: context.Background(), apiUrl: api.URL + "/api/v3"}
+
+	if _, err := s.load("127.0.0.1", "cid"); !errors.Is(err, errTokenNotFound) {
+		t.Fatalf("load of a missing host = %v, want errTokenNotFound", err)
+	}
+	if err := s.save("127.0.0.1", "cid", &storedToken{AccessToken: testToken}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.load("127.0.0.1", "ignored"); err != nil || got.AccessToken != testToken {
+		t.Errorf("load = %+v, %v", got, err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"git_protocol: ssh", "user: hubot", "git_protocol: https", "user: octocat"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("hosts.yml = %s, want %s", b, want)
+		}
+	}
+
+	if err := s.erase("127.0.0.1", "cid"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.load("github.com", "cid"); err != nil || got.AccessToken != "gho_other" {
+		t.Errorf("load of the other host = %+v, %v", got, err)
+	}
+}
+
+func TestDefaultGhHostsPath(t *testing.T) {
+	home := setupEnv(t)
+	if got, want := defaultGhHostsPath(), filepath.Join(home, ".config", "gh", "hosts.yml"); got != want {
+		t.Errorf("defaultGhHostsPath = %s, want %s", got, want)
+	}
+	t.Setenv("GH_CONFIG_DIR", "/etc/gh")
+	if got, want := defaultGhHostsPath(), filepath.Join("/etc/gh", "hosts.yml"); got != want {
+		t.Errorf("defaultGhHostsPath = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringStore stores tokens in macOS Keychain, Windows Credential Manager
+// or the Secret Service (libsecret) on Linux
+type keyringStore struct{}
+
+func keyringService(host string) string {
+	return "gh-device:" + host
+}
+
+func (keyringStore) load(host, clientId string) (*storedToken, error) {
+	s, err := keyring.Get(keyringService(host), clientId)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, errTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &storedToken{}
+	err = json.Unmarshal([]byte(s), t)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (keyringStore) save(host, clientId string, t *storedToken) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(keyringService(host), clientId, string(b))
+}
+
+func (keyringStore) erase(host, clientId string) error {
+	err := keyring.Delete(keyringService(host), clientId)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
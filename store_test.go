@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// memoryStore keeps tokens for the tests of the commands
+type memoryStore map[string]*storedToken
+
+func (s memoryStore) load(host, clientId string) (*storedToken, error) {
+	t, ok := s[tokenKey(host, clientId)]
+	if !ok {
+		return nil, errTokenNotFound
+	}
+	return t, nil
+}
+
+func (s memoryStore) save(host, clientId string, t *storedToken) error {
+	s[tokenKey(host, clientId)] = t
+	return nil
+}
+
+func (s memoryStore) erase(host, clientId string) error {
+	delete(s, tokenKey(host, clientId))
+	return nil
+}
+
+func TestStoredTokenRefresh(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		token        *storedToken
+		needsRefresh bool
+		canRefresh   bool
+	}{
+		{"no expiry", &storedToken{AccessToken: "t"}, false, false},
+		{"valid", &storedToken{ExpiresAt: now.Add(time.Hour), RefreshToken: "r"}, false, true},
+		{"expiring", &storedToken{ExpiresAt: now.Add(time.Minute), RefreshToken: "r"}, true, true},
+		{"no refresh token", &storedToken{ExpiresAt: now.Add(-time.Hour)}, true, false},
+		{"refresh token valid", &storedToken{ExpiresAt: now, RefreshToken: "r", RefreshTokenExpiresAt: now.Add(time.Hour)}, true, true},
+		{"refresh token expiring", &storedToken{ExpiresAt: now, RefreshToken: "r", RefreshTokenExpiresAt: now.Add(time.Minute)}, true, false},
+		{"refresh token expired", &storedToken{ExpiresAt: now, RefreshToken: "r", RefreshTokenExpiresAt: now.Add(-time.Hour)}, true, false},
+	}
+	for _, tt := range tests {
+		if got := tt.token.needsRefresh(); got != tt.needsRefresh {
+			t.Errorf("%s: needsRefresh = %v, want %v", tt.name, got, tt.needsRefresh)
+		}
+		if got := tt.token.canRefresh(); got != tt.canRefresh {
+			t.Errorf("%s: canRefresh = %v, want %v", tt.name, got, tt.canRefresh)
+		}
+	}
+}
+
+func TestNewStoredToken(t *testing.T) {
+	st := newStoredToken(&deviceflow.AccessTokenResponse{AccessToken: "t", RefreshToken: "r", ExpiresIn: 28800, RefreshTokenExpiresIn: 15897600})
+	if st.ExpiresAt.Sub(st.CreatedAt) != 8*time.Hour || st.RefreshTokenExpiresAt.Sub(st.CreatedAt) != 184*24*time.Hour {
+		t.Errorf("stored token = %+v", st)
+	}
+	st = newStoredToken(&deviceflow.AccessTokenResponse{AccessToken: "t"})
+	if !st.ExpiresAt.IsZero() || !st.RefreshTokenExpiresAt.IsZero() {
+		t.Errorf("stored token = %+v, want no expiry", st)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	setupEnv(t)
+	t.Setenv("GITHUB_OAUTH_STORE_PASSPHRASE", "correct horse")
+	path := filepath.Join(t.TempDir(), "tokens.enc")
+	s := &fileStore{path: path}
+
+	if _, err := s.load("github.com", "cid"); !errors.Is(err, errTokenNotFound) {
+		t.Fatalf("load without a file = %v, want errTokenNotFound", err)
+	}
+	if err := s.save("github.com", "cid", &storedToken{AccessToken: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save("ghe.example.com", "cid", &storedToken{AccessToken: "t2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the passphrase is read once per store
+	t.Setenv("GITHUB_OAUTH_STORE_PASSPHRASE", "wrong")
+	if got, err := s.load("github.com", "cid"); err != nil || got.AccessToken != "t1" {
+		t.Errorf("load = %+v, %v", got, err)
+	}
+	if _, err := (&fileStore{path: path}).load("github.com", "cid"); err == nil {
+		t.Error("load with a wrong passphrase = nil, want an error")
+	}
+
+	if err := s.erase("github.com", "cid"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.load("github.com", "cid"); !errors.Is(err, errTokenNotFound) {
+		t.Errorf("load after erase = %v, want errTokenNotFound", err)
+	}
+	if got, err := s.load("ghe.example.com", "cid"); err != nil || got.AccessToken != "t2" {
+		t.Errorf("load of the other host = %+v, %v", got, err)
+	}
+}
+
+func TestFileStoreRequiresPassphrase(t *testing.T) {
+	setupEnv(t)
+	s := &fileStore{path: filepath.Join(t.TempDir(), "tokens.enc")}
+	// stdin of the tests is not a terminal
+	if err := s.save("github.com", "cid", &storedToken{AccessToken: "t"}); err == nil {
+		t.Error("save without a passphrase = nil, want an error")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package main
+
+import "errors"
+
+func writeAuditLog(login, scope, fingerprint string) error {
+	return errors.New("syslog is not supported on this platform")
+}
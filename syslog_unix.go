@@ -0,0 +1,23 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+func writeAuditLog(login, scope, fingerprint string) error {
+	w, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTH, "go-github-oauth-device-flow-example")
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	return w.Notice(auditMessage(login, scope, fingerprint))
+}
+
+// auditMessage never includes the token itself, only its fingerprint
+func auditMessage(login, scope, fingerprint string) string {
+	return fmt.Sprintf("access token issued: login=%s scope=%q fingerprint=%s", login, scope, fingerprint)
+}
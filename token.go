@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+func loadStoredToken(ctx context.Context, st *settings) (tokenStore, *storedToken, error) {
+	store, err := st.tokenStore(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if store == nil {
+		return nil, nil, errors.New("-store is required")
+	}
+	t, err := store.load(*st.host, *st.clientId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, t, nil
+}
+
+// refreshStoredToken exchanges the refresh token of t for a new token and stores it
+func refreshStoredToken(ctx context.Context, st *settings, store tokenStore, t *storedToken) (*storedToken, error) {
+	if t.RefreshToken == "" {
+		return nil, errors.New("the stored token cannot be refreshed, run login again")
+	}
+	if !t.canRefresh() {
+		return nil, fmt.Errorf("the refresh token expired at %s, run login again", t.RefreshTokenExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+	provider, err := st.provider()
+	if err != nil {
+		return nil, err
+	}
+
+	acResp, err := st.newFlow(provider, "").Refresh(ctx, t.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	nt := newStoredToken(acResp)
+	if err := store.save(*st.host, *st.clientId, nt); err != nil {
+		return nil, err
+	}
+	return nt, nil
+}
+
+// loadValidToken returns the stored token, refreshing it first if it is about to expire
+func loadValidToken(ctx context.Context, st *settings) (*storedToken, error) {
+	store, t, err := loadStoredToken(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+	if t.needsRefresh() {
+		return refreshStoredToken(ctx, st, store, t)
+	}
+	return t, nil
+}
+
+// ensureToken returns the stored token, running the device flow
+// with prompts on stderr if there is none or it expires without a usable refresh token.
+// store may be nil to not keep the token.
+func ensureToken(ctx context.Context, st *settings, store tokenStore) (*storedToken, error) {
+	if store != nil {
+		t, err := store.load(*st.host, *st.clientId)
+		if err != nil && !errors.Is(err, errTokenNotFound) {
+			return nil, err
+		}
+		if err == nil && !t.needsRefresh() {
+			return t, nil
+		}
+		if err == nil && t.canRefresh() {
+			return refreshStoredToken(ctx, st, store, t)
+		}
+	}
+
+	if *st.clientId == "" {
+		return nil, errors.New("client ID is required, use -client-id")
+	}
+	provider, err := st.provider()
+	if err != nil {
+		return nil, err
+	}
+	flow := st.newFlow(provider, strings.Join(deviceflow.ParseScopes(*st.scope), " "))
+	acResp, err := authenticate(ctx, flow, &prompter{out: os.Stderr, hyperlinks: "auto", openBrowser: true})
+	if err != nil {
+		return nil, err
+	}
+	t := newStoredToken(acResp)
+	if store != nil {
+		if err := store.save(*st.host, *st.clientId, t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// runToken prints the stored access token, refreshing it first if it is about to expire
+func runToken(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	t, err := loadValidToken(ctx, st)
+	if err != nil {
+		return err
+	}
+	fmt.Println(t.AccessToken)
+	return nil
+}
+
+// runRefresh exchanges the refresh token of the stored token for a new one
+func runRefresh(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("refresh", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	force := flags.Bool("force", false, "refresh even if the token is not about to expire")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	store, t, err := loadStoredToken(ctx, st)
+	if err != nil {
+		return err
+	}
+	if !*force && !t.needsRefresh() {
+		fmt.Println("the stored token is not about to expire")
+		return nil
+	}
+	t, err = refreshStoredToken(ctx, st, store, t)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("refreshed, expires at %s\n", t.ExpiresAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// runLogout revokes the stored token on GitHub if the client secret is known, and removes it
+func runLogout(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("logout", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	grant := flags.Bool("grant", false, "revoke the whole authorization of the app, i.e. all its tokens for the user")
+	local := flags.Bool("local", false, "only remove the stored token without revoking it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	store, t, err := loadStoredToken(ctx, st)
+	if errors.Is(err, errTokenNotFound) {
+		logger.Info("not logged in", "host", *st.host)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !*local && *st.providerName == "github" {
+		if *st.clientSecret == "" {
+			logger.Warn("the token is not revoked on GitHub without -client-secret, only removed locally")
+		} else if err := revokeToken(ctx, st.apiUrl, *st.clientId, *st.clientSecret, t.AccessToken, *grant); err != nil {
+			return err
+		}
+	}
+	if err := store.erase(*st.host, *st.clientId); err != nil {
+		return err
+	}
+	logger.Info("logged out", "host", *st.host)
+	return nil
+}
+
+// runStatus reports whether a valid token is stored and its scopes
+func runStatus(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	_, t, err := loadStoredToken(ctx, st)
+	if err != nil {
+		return err
+	}
+
+	scope := t.Scope
+	if *st.providerName == "github" {
+		resp, body, err := get(ctx, st.apiUrl+"/user", t.AccessToken)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("the stored token for %s is no longer valid: %s", *st.host, resp.Status)
+		}
+		u := &user{}
+		if err := json.Unmarshal(body, u); err != nil {
+			return err
+		}
+		fmt.Printf("Logged in to %s as %s\n", *st.host, u.Login)
+		if granted, ok := grantedScopes(resp); ok {
+			scope = strings.Join(granted, ",")
+		}
+	} else {
+		if !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt) {
+			return fmt.Errorf("the stored token for %s is expired", *st.host)
+		}
+		fmt.Printf("Logged in to %s\n", *st.host)
+	}
+
+	fmt.Printf("Token: %s\n", fingerprint(t.AccessToken))
+	if scopes := deviceflow.ParseScopes(scope); len(scopes) > 0 {
+		fmt.Printf("Scopes: %s\n", strings.Join(scopes, ", "))
+	} else {
+		fmt.Println("Scopes: none")
+	}
+	if !t.ExpiresAt.IsZero() {
+		fmt.Printf("Expires at: %s\n", t.ExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnsureToken(t *testing.T) {
+	setupEnv(t)
+	s := newFakeGitHub(t)
+	st, flags := newSettings(t, "-config", s.config, "-host", "127.0.0.1", "-client-id", "cid")
+	if err := st.resolve(flags); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		store := memoryStore{}
+		store.save("127.0.0.1", "cid", &storedToken{AccessToken: "stored", ExpiresAt: time.Now().Add(time.Hour)})
+		got, err := ensureToken(ctx, st, store)
+		if err != nil || got.AccessToken != "stored" {
+			t.Errorf("ensureToken = %+v, %v, want the stored token", got, err)
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		store := memoryStore{}
+		store.save("127.0.0.1", "cid", &storedToken{AccessToken: "stored", ExpiresAt: time.Now(), RefreshToken: "ghr_refresh"})
+		got, err := ensureToken(ctx, st, store)
+		if err != nil || got.AccessToken != testToken {
+			t.Fatalf("ensureToken = %+v, %v, want the refreshed token", got, err)
+		}
+		if form := s.forms[len(s.forms)-1]; !strings.Contains(form, "grant_type=refresh_token") || !strings.Contains(form, "refresh_token=ghr_refresh") {
+			t.Errorf("form = %s, want a refresh", form)
+		}
+		if deviceCodes, _ := s.counts(); deviceCodes != 0 {
+			t.Errorf("device code requests = %d, want 0", deviceCodes)
+		}
+	})
+
+	t.Run("expired refresh token", func(t *testing.T) {
+		store := memoryStore{}
+		store.save("127.0.0.1", "cid", &storedToken{AccessToken: "stored", ExpiresAt: time.Now(), RefreshToken: "ghr_refresh", RefreshTokenExpiresAt: time.Now()})
+		var got *storedToken
+		_, stderr, err := capture(t, func() (err error) {
+			got, err = ensureToken(ctx, st, store)
+			return err
+		})
+		if err != nil || got.AccessToken != testToken {
+			t.Fatalf("ensureToken = %+v, %v, want a new token", got, err)
+		}
+		if !strings.Contains(stderr, testUserCode) {
+			t.Errorf("stderr = %q, want the device flow prompt", stderr)
+		}
+		if saved, _ := store.load("127.0.0.1", "cid"); saved.AccessToken != testToken {
+			t.Errorf("stored token = %+v, want the new token", saved)
+		}
+	})
+}
+
+func TestRefreshStoredTokenExpired(t *testing.T) {
+	setupEnv(t)
+	st, flags := newSettings(t, "-client-id", "cid")
+	if err := st.resolve(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := refreshStoredToken(context.Background(), st, memoryStore{}, &storedToken{RefreshToken: "r", RefreshTokenExpiresAt: time.Now().Add(-time.Hour)})
+	if err == nil || !strings.Contains(err.Error(), "the refresh token expired at") {
+		t.Errorf("err = %v, want the refresh token expiry", err)
+	}
+	_, err = refreshStoredToken(context.Background(), st, memoryStore{}, &storedToken{})
+	if err == nil || !strings.Contains(err.Error(), "cannot be refreshed") {
+		t.Errorf("err = %v, want no refresh token", err)
+	}
+}
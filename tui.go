@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+// 3x5 glyphs for the user code, GitHub user codes only use letters, digits and a hyphen
+var bigFont = map[rune][5]string{
+	'0': {"###", "# #", "# #", "# #", "###"},
+	'1': {" # ", "## ", " # ", " # ", "###"},
+	'2': {"###", "  #", "###", "#  ", "###"},
+	'3': {"###", "  #", "###", "  #", "###"},
+	'4': {"# #", "# #", "###", "  #", "  #"},
+	'5': {"###", "#  ", "###", "  #", "###"},
+	'6': {"###", "#  ", "###", "# #", "###"},
+	'7': {"###", "  #", "  #", "  #", "  #"},
+	'8': {"###", "# #", "###", "# #", "###"},
+	'9': {"###", "# #", "###", "  #", "###"},
+	'A': {" # ", "# #", "###", "# #", "# #"},
+	'B': {"## ", "# #", "## ", "# #", "## "},
+	'C': {"###", "#  ", "#  ", "#  ", "###"},
+	'D': {"## ", "# #", "# #", "# #", "## "},
+	'E': {"###", "#  ", "## ", "#  ", "###"},
+	'F': {"###", "#  ", "## ", "#  ", "#  "},
+	'G': {"###", "#  ", "# #", "# #", "###"},
+	'H': {"# #", "# #", "###", "# #", "# #"},
+	'I': {"###", " # ", " # ", " # ", "###"},
+	'J': {"  #", "  #", "  #", "# #", "###"},
+	'K': {"# #", "# #", "## ", "# #", "# #"},
+	'L': {"#  ", "#  ", "#  ", "#  ", "###"},
+	'M': {"# #", "###", "###", "# #", "# #"},
+	'N': {"## ", "# #", "# #", "# #", "# #"},
+	'O': {"###", "# #", "# #", "# #", "###"},
+	'P': {"###", "# #", "###", "#  ", "#  "},
+	'Q': {"###", "# #", "# #", "###", "  #"},
+	'R': {"## ", "# #", "## ", "# #", "# #"},
+	'S': {"###", "#  ", "###", "  #", "###"},
+	'T': {"###", " # ", " # ", " # ", " # "},
+	'U': {"# #", "# #", "# #", "# #", "###"},
+	'V': {"# #", "# #", "# #", "# #", " # "},
+	'W': {"# #", "# #", "###", "###", "# #"},
+	'X': {"# #", "# #", " # ", "# #", "# #"},
+	'Y': {"# #", "# #", " # ", " # ", " # "},
+	'Z': {"###", "  #", " # ", "#  ", "###"},
+	'-': {"   ", "   ", "###", "   ", "   "},
+}
+
+// bigText returns the lines of s in large type, or nil if s has a character without a glyph
+func bigText(s string) []string {
+	lines := make([]string, 5)
+	for i, r := range strings.ToUpper(s) {
+		glyph, ok := bigFont[r]
+		if !ok {
+			return nil
+		}
+		for row := range lines {
+			if i > 0 {
+				lines[row] += "  "
+			}
+			// each pixel is two cells wide to look square
+			lines[row] += strings.NewReplacer("#", "██", " ", "  ").Replace(glyph[row])
+		}
+	}
+	return lines
+}
+
+// loginScreenState is what the login screen shows
+type loginScreenState struct {
+	provider  string
+	dcResp    *deviceflow.DeviceCodeResponse
+	expiresAt time.Time
+	status    string
+	polls     int
+}
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// renderLoginScreen returns the screen for st at now, including the escape sequences clearing the screen
+func renderLoginScreen(st loginScreenState, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J\n")
+	fmt.Fprintf(&b, "  Sign in to %s\n\n", st.provider)
+	fmt.Fprintf(&b, "  Open \x1b[4m%s\x1b[0m in your browser and enter this code:\n\n", st.dcResp.VerificationURI)
+	for _, line := range bigText(st.dcResp.UserCode) {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	fmt.Fprintf(&b, "\n    \x1b[1m%s\x1b[0m\n\n", st.dcResp.UserCode)
+
+	remaining := st.expiresAt.Sub(now).Round(time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+	fmt.Fprintf(&b, "  Expires in %d:%02d\n", int(remaining.Minutes()), int(remaining.Seconds())%60)
+	// the screen is redrawn every second, which turns the spinner
+	spinner := spinnerFrames[int(now.Unix())%len(spinnerFrames)]
+	fmt.Fprintf(&b, "  %s %s (checked %d times)\n\n", spinner, st.status, st.polls)
+	b.WriteString("  Press Ctrl+C to cancel\n")
+	return b.String()
+}
+
+// loginScreen is a full-screen view of the device flow on the alternate screen buffer,
+// redrawn every second for the countdown
+type loginScreen struct {
+	out *os.File
+
+	mu    sync.Mutex
+	state loginScreenState
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newLoginScreen(out *os.File, provider string, dcResp *deviceflow.DeviceCodeResponse, expiresAt time.Time) *loginScreen {
+	return &loginScreen{
+		out: out,
+		state: loginScreenState{
+			provider:  provider,
+			dcResp:    dcResp,
+			expiresAt: expiresAt,
+			status:    "Waiting for authorization...",
+		},
+		done: make(chan struct{}),
+	}
+}
+func (s *loginScreen) start() {
+	// alternate screen buffer, hide the cursor
+	fmt.Fprint(s.out, "\x1b[?1049h\x1b[?25l")
+	s.draw()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.draw()
+			}
+		}
+	}()
+}
+
+// stop restores the screen as it was before start
+func (s *loginScreen) stop() {
+	close(s.done)
+	s.wg.Wait()
+	fmt.Fprint(s.out, "\x1b[?25h\x1b[?1049l")
+}
+
+func (s *loginScreen) pending() {
+	s.mu.Lock()
+	s.state.polls++
+	s.mu.Unlock()
+	s.draw()
+}
+
+func (s *loginScreen) draw() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprint(s.out, renderLoginScreen(s.state, time.Now()))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lusingander/go-github-oauth-device-flow-example/deviceflow"
+)
+
+func TestRenderLoginScreen(t *testing.T) {
+	now := time.Unix(1700000001, 0)
+	st := loginScreenState{
+		provider:  "github",
+		dcResp:    &deviceflow.DeviceCodeResponse{UserCode: "WDJB-MJHT", VerificationURI: "https://github.com/login/device"},
+		expiresAt: now.Add(125 * time.Second),
+		status:    "Waiting for authorization...",
+		polls:     3,
+	}
+
+	got := renderLoginScreen(st, now)
+	for _, want := range []string{
+		"\x1b[H\x1b[2J",
+		"Sign in to github\n",
+		"Open \x1b[4mhttps://github.com/login/device\x1b[0m in your browser",
+		"    " + bigText("WDJB-MJHT")[0] + "\n",
+		"\x1b[1mWDJB-MJHT\x1b[0m",
+		"Expires in 2:05\n",
+		"  / Waiting for authorization... (checked 3 times)\n",
+		"Press Ctrl+C to cancel\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("render = %q, want %q", got, want)
+		}
+	}
+
+	got = renderLoginScreen(st, now.Add(time.Hour))
+	if !strings.Contains(got, "Expires in 0:00\n") {
+		t.Errorf("render after the expiry = %q, want 0:00", got)
+	}
+	if strings.Contains(renderLoginScreen(st, now.Add(time.Second)), "  / ") {
+		t.Error("the spinner does not turn")
+	}
+}
+
+func TestBigText(t *testing.T) {
+	lines := bigText("A-1")
+	if len(lines) != 5 || lines[2] != "██████  ██████    ██  " {
+		t.Errorf("bigText = %q", lines)
+	}
+	if bigText("a?") != nil {
+		t.Error("bigText with a character without a glyph != nil")
+	}
+}
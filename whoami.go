@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// runWhoami prints the user the stored token belongs to and its scopes
+func runWhoami(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("whoami", flag.ExitOnError)
+	st := addSettingsFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := st.resolve(flags); err != nil {
+		return err
+	}
+
+	t, err := loadValidToken(ctx, st)
+	if err != nil {
+		return err
+	}
+	resp, body, err := get(ctx, st.apiUrl+"/user", t.AccessToken)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get the authenticated user: %s", resp.Status)
+	}
+	u := &user{}
+	if err := json.Unmarshal(body, u); err != nil {
+		return err
+	}
+
+	fmt.Printf("login: %s\n", u.Login)
+	fmt.Printf("name: %s\n", u.Name)
+	if scopes, ok := grantedScopes(resp); ok {
+		fmt.Printf("scopes: %s\n", strings.Join(scopes, ", "))
+	} else {
+		fmt.Println("scopes: (not reported for this token type)")
+	}
+	return nil
+}